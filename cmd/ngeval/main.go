@@ -0,0 +1,74 @@
+package main
+
+import (
+	"ebitenprac/ngword"
+	"ebitenprac/ngword/eval"
+	"flag"
+	"log"
+	"os"
+	"strings"
+)
+
+var (
+	dictFile    = flag.String("dict", "resource/ngwords.origin.csv", "NG word dictionary")
+	dataFile    = flag.String("data", "", "labeled CSV of sentences")
+	sentenceCol = flag.String("sentence", "sentence", "column holding the sentences")
+	labelCol    = flag.String("label", "label", "column holding the labels")
+	filterNames = flag.String("filter", "trie", "comma separated filters to compare: "+strings.Join(ngword.FilterNames, ", "))
+	details     = flag.Bool("details", false, "print confusion matrices and per word counts")
+
+	// cfg is set by the matcher flags for every filter compared
+	cfg ngword.FilterConfig
+)
+
+func init() {
+	cfg.RegisterFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+	if *dataFile == "" {
+		log.Fatal("-data is required")
+	}
+	if err := cfg.CheckFlags(flag.CommandLine); err != nil {
+		log.Fatal(err)
+	}
+
+	dict, err := ngword.LoadDataframeFromCSV(*dictFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fp, err := os.Open(*dataFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	samples, err := eval.ReadSamples(fp, *sentenceCol, *labelCol)
+	fp.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	reports := make([]eval.Report, 0)
+	names := strings.Split(*filterNames, ",")
+	if cfg.Pipeline != "" {
+		names = []string{"pipeline"}
+	}
+	for _, name := range names {
+		c := cfg
+		c.Filter = strings.TrimSpace(name)
+		f, err := c.Build(dict)
+		if err != nil {
+			log.Fatal(err)
+		}
+		reports = append(reports, eval.Evaluate(c.Filter, f, samples))
+	}
+
+	if *details {
+		for _, r := range reports {
+			eval.WriteReport(os.Stdout, r)
+		}
+	}
+	if err := eval.WriteSummary(os.Stdout, reports); err != nil {
+		log.Fatal(err)
+	}
+}
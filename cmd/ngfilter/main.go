@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"ebitenprac/ngword"
+	"flag"
+	"fmt"
+	"github.com/go-gota/gota/dataframe"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+var (
+	dictFile  = flag.String("dict", "resource/ngwords.origin.csv", "NG word dictionary")
+	inFile    = flag.String("in", "", "input file (default stdin)")
+	outFile   = flag.String("out", "", "output file (default stdout)")
+	csvInput  = flag.Bool("csv", false, "read the input as CSV instead of one sentence per line")
+	column    = flag.String("col", "", "CSV column holding the sentences (default first column)")
+	workers   = flag.Int("workers", 0, "sentences filtered at once (default one per CPU)")
+	progress  = flag.Int("progress", 0, "log progress every this many sentences (0 disables)")
+	withScore = flag.Bool("score", false, "also output the toxicity of each sentence, from 0 to 1")
+	maskMode  = flag.String("mask", "", "masking of NG words: full, keep-first, token, label or remove (trie, romanized and pipeline filters)")
+
+	minSeverity = flag.Int("min-severity", 0, "only use dictionary entries of at least this severity")
+	category    = flag.String("category", "", "only use dictionary entries of these comma separated categories")
+	lang        = flag.String("lang", "", "only use dictionary entries for this language")
+	country     = flag.String("country", "", "only use dictionary entries for this country")
+	usage       = flag.String("usage", "", "only use dictionary entries for this usage")
+
+	// cfg is set by -filter and the matcher flags; -pipeline also adds the
+	// action taken to the output
+	cfg ngword.FilterConfig
+)
+
+func init() {
+	flag.StringVar(&cfg.Filter, "filter", "trie", "filter to run: "+strings.Join(ngword.FilterNames, ", "))
+	cfg.RegisterFlags(flag.CommandLine)
+}
+
+func selector() ngword.Selector {
+	sel := ngword.Selector{
+		MinSeverity: *minSeverity,
+		Lang:        *lang,
+		Country:     *country,
+		Usage:       *usage,
+	}
+	if *category != "" {
+		sel.Categories = strings.Split(*category, ",")
+	}
+	return sel
+}
+
+// masked makes filter, named name, rewrite NG words with the mask mode named
+// mode.
+func masked(name string, filter ngword.Filter, mode string) (ngword.Filter, error) {
+	var m ngword.Masking
+	if err := m.Mode.UnmarshalText([]byte(mode)); err != nil {
+		return nil, err
+	}
+	switch f := filter.(type) {
+	case *ngword.Pipeline:
+		f.Policy.Masking.Mode = m.Mode
+		return f, nil
+	case ngword.Detector:
+		return ngword.NewMasked(f, m), nil
+	}
+	return nil, fmt.Errorf("filter %q has no mask modes", name)
+}
+
+func main() {
+	flag.Parse()
+	if err := cfg.CheckFlags(flag.CommandLine); err != nil {
+		log.Fatal(err)
+	}
+
+	dict, err := ngword.LoadDataframeFromCSV(*dictFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if dict, err = selector().Select(dict); err != nil {
+		log.Fatal(err)
+	}
+	filter, err := cfg.Build(dict)
+	if err != nil {
+		log.Fatal(err)
+	}
+	name := cfg.Filter
+	if cfg.Pipeline != "" {
+		name = "pipeline"
+	}
+	if *maskMode != "" {
+		if filter, err = masked(name, filter, *maskMode); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var in io.Reader = os.Stdin
+	if *inFile != "" {
+		fp, err := os.Open(*inFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer fp.Close()
+		in = fp
+	}
+	out := bufio.NewWriter(os.Stdout)
+	if *outFile != "" {
+		fp, err := os.Create(*outFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer fp.Close()
+		out = bufio.NewWriter(fp)
+	}
+	defer out.Flush()
+
+	if *csvInput {
+		df := dataframe.ReadCSV(in, dataframe.DetectTypes(false))
+		if df.Err != nil {
+			log.Fatal(df.Err)
+		}
+		ff := ngword.NewFrameFilter(filter)
+		ff.Input = *column
+		if *withScore {
+			ff.Toxicity = "toxicity"
+		}
+		if cfg.Pipeline != "" {
+			ff.Action = "action"
+		}
+		df, err := ff.Do(df)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := df.WriteCSV(out); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	st := ngword.NewStream(filter)
+	st.Workers = *workers
+	if *progress > 0 {
+		st.Progress = func(done int) {
+			if done%*progress == 0 {
+				log.Printf("%d sentences", done)
+			}
+		}
+	}
+	var scanErr error
+	for r := range st.FilterStream(context.Background(), ngword.Lines(context.Background(), in, &scanErr)) {
+		predict := 0
+		if r.Changed {
+			predict = 1
+		}
+		fmt.Fprintf(out, "%d\t", predict)
+		if cfg.Pipeline != "" {
+			fmt.Fprintf(out, "%s\t", r.Action)
+		}
+		if *withScore {
+			fmt.Fprintf(out, "%.3f\t", r.Toxicity)
+		}
+		fmt.Fprintln(out, r.Filtered)
+	}
+	if scanErr != nil {
+		log.Fatal(scanErr)
+	}
+}
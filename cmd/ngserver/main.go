@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"ebitenprac/ngword"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	addr        = flag.String("addr", "127.0.0.1:8080", "listen address")
+	dictFile    = flag.String("dict", "resource/ngwords.origin.csv", "NG word dictionary")
+	maxBody     = flag.Int64("max-body", 1<<20, "maximum request body in bytes")
+	maxBatch    = flag.Int("max-batch", 1000, "maximum sentences per batch request")
+	maxInflight = flag.Int("max-inflight", 8, "maximum requests filtered at the same time")
+	watch       = flag.Duration("watch", 0, "poll the dictionary for changes at this interval (0 disables)")
+	timeout     = flag.Duration("timeout", 0, "give up a request, a whole batch included, after this long (0 disables)")
+
+	minSeverity = flag.Int("min-severity", 0, "only use dictionary entries of at least this severity")
+	category    = flag.String("category", "", "only use dictionary entries of these comma separated categories")
+	lang        = flag.String("lang", "", "only use dictionary entries for this language")
+	country     = flag.String("country", "", "only use dictionary entries for this country")
+	usage       = flag.String("usage", "", "only use dictionary entries for this usage")
+
+	// cfg is the trie filter set by the matcher flags, or -pipeline
+	cfg = ngword.FilterConfig{Filter: "trie"}
+)
+
+func init() {
+	cfg.RegisterFlags(flag.CommandLine)
+}
+
+func selector() ngword.Selector {
+	sel := ngword.Selector{
+		MinSeverity: *minSeverity,
+		Lang:        *lang,
+		Country:     *country,
+		Usage:       *usage,
+	}
+	if *category != "" {
+		sel.Categories = strings.Split(*category, ",")
+	}
+	return sel
+}
+
+// detector is what the handlers filter with.
+type detector interface {
+	ngword.Filter
+	DecideContext(ctx context.Context, sentence string) (ngword.Decision, error)
+	DetectContext(ctx context.Context, sentence string) ([]ngword.Match, error)
+}
+
+type Server struct {
+	detector detector
+	// pipelined reports the Action taken on each sentence
+	pipelined bool
+	dict      *ngword.DictionaryManager
+	token     chan struct{}
+}
+
+func NewServer(dict string, inflight int) (*Server, error) {
+	s := &Server{
+		dict:  ngword.NewDictionaryManager(dict),
+		token: make(chan struct{}, inflight),
+	}
+	if err := s.dict.Load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Use filters with the filter of c, kept reloaded with the entries of sel.
+func (s *Server) Use(c ngword.FilterConfig, sel ngword.Selector) error {
+	f, err := c.Build(s.dict.Dictionary())
+	if err != nil {
+		return err
+	}
+	d, ok := f.(detector)
+	if !ok {
+		return fmt.Errorf("filter %q cannot serve requests", c.Filter)
+	}
+	r, ok := f.(ngword.Reloader)
+	if !ok {
+		return fmt.Errorf("filter %q cannot be reloaded", c.Filter)
+	}
+	if err := s.dict.Register(ngword.Selected(sel, r)); err != nil {
+		return err
+	}
+	s.detector = d
+	s.pipelined = c.Pipeline != ""
+	return nil
+}
+
+type replaceRequest struct {
+	Sentence string `json:"sentence"`
+	// Mask overrides the masking of NG words for this request.
+	Mask *ngword.Masking `json:"mask,omitempty"`
+}
+
+type replaceResponse struct {
+	Filtered string `json:"filtered"`
+	Changed  bool   `json:"changed"`
+	// Toxicity rates the NG words found, from 0 to 1; see ngword.Toxicity.
+	Toxicity float64 `json:"toxicity"`
+	// Action is the decision of the pipeline, if one is used.
+	Action string `json:"action,omitempty"`
+}
+
+type detectResponse struct {
+	Sentence string         `json:"sentence"`
+	Matches  []ngword.Match `json:"matches"`
+}
+
+type batchRequest struct {
+	Sentences []string        `json:"sentences"`
+	Mask      *ngword.Masking `json:"mask,omitempty"`
+}
+
+type batchResponse struct {
+	Results []replaceResponse `json:"results"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Print(err)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, errorResponse{Error: err.Error()})
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	r.Body = http.MaxBytesReader(w, r.Body, *maxBody)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// limit rejects a request with 503 when max-inflight requests are already
+// being filtered instead of queueing it.
+func (s *Server) limit(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, errors.New("POST only"))
+			return
+		}
+		select {
+		case s.token <- struct{}{}:
+			defer func() { <-s.token }()
+			h(w, r)
+		default:
+			writeError(w, http.StatusServiceUnavailable, errors.New("too many requests"))
+		}
+	}
+}
+
+// context bounds the filtering of r by -timeout and by the client staying
+// connected.
+func (s *Server) context(r *http.Request) (context.Context, context.CancelFunc) {
+	if *timeout > 0 {
+		return context.WithTimeout(r.Context(), *timeout)
+	}
+	return context.WithCancel(r.Context())
+}
+
+// masked is the detector rewriting NG words with m, s.detector itself if m is
+// nil. A pipeline keeps its policy apart from the masking.
+func (s *Server) masked(m *ngword.Masking) detector {
+	if m == nil {
+		return s.detector
+	}
+	if p, ok := s.detector.(*ngword.Pipeline); ok {
+		q := *p
+		q.Policy.Masking = *m
+		return &q
+	}
+	return ngword.NewMasked(s.detector, *m)
+}
+
+func (s *Server) handleReplace(w http.ResponseWriter, r *http.Request) {
+	var req replaceRequest
+	if err := readJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	ctx, cancel := s.context(r)
+	defer cancel()
+	det := s.masked(req.Mask)
+	d, err := det.DecideContext(ctx, req.Sentence)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	res := replaceResponse{
+		Filtered: d.Filtered,
+		Changed:  d.Action != ngword.Pass,
+		Toxicity: d.Toxicity,
+	}
+	if s.pipelined {
+		res.Action = d.Action.String()
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) handleDetect(w http.ResponseWriter, r *http.Request) {
+	var req replaceRequest
+	if err := readJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	ctx, cancel := s.context(r)
+	defer cancel()
+	matches, err := s.detector.DetectContext(ctx, req.Sentence)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, detectResponse{
+		Sentence: req.Sentence,
+		Matches:  matches,
+	})
+}
+
+func (s *Server) handleBatch(w http.ResponseWriter, r *http.Request) {
+	var req batchRequest
+	if err := readJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(req.Sentences) > *maxBatch {
+		writeError(w, http.StatusRequestEntityTooLarge, errors.New("too many sentences"))
+		return
+	}
+	ctx, cancel := s.context(r)
+	defer cancel()
+	res := batchResponse{Results: make([]replaceResponse, len(req.Sentences))}
+	done := 0
+	det := s.masked(req.Mask)
+	st := ngword.NewStream(det)
+	for fr := range st.FilterStream(ctx, ngword.Strings(ctx, req.Sentences)) {
+		if fr.Err != nil {
+			writeError(w, http.StatusServiceUnavailable, fr.Err)
+			return
+		}
+		res.Results[fr.Index] = replaceResponse{Filtered: fr.Filtered, Changed: fr.Changed, Toxicity: fr.Toxicity}
+		if s.pipelined {
+			res.Results[fr.Index].Action = fr.Action.String()
+		}
+		done++
+	}
+	// the stream stops short without an error once ctx is done
+	if done < len(req.Sentences) {
+		writeError(w, http.StatusServiceUnavailable, ctx.Err())
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if err := s.dict.Load(); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.handleHealth(w, r)
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	res := map[string]string{
+		"status":     "ok",
+		"dictionary": s.dict.Path,
+	}
+	if err := s.dict.Err(); err != nil {
+		res["status"] = "degraded"
+		res["error"] = err.Error()
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func main() {
+	flag.Parse()
+	if err := cfg.CheckFlags(flag.CommandLine); err != nil {
+		log.Fatal(err)
+	}
+
+	s, err := NewServer(*dictFile, *maxInflight)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := s.Use(cfg, selector()); err != nil {
+		log.Fatal(err)
+	}
+
+	if *watch > 0 {
+		s.dict.Interval = *watch
+		s.dict.OnError = func(err error) {
+			log.Print(err)
+		}
+		s.dict.Start()
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.handleHealth)
+	mux.HandleFunc("/replace", s.limit(s.handleReplace))
+	mux.HandleFunc("/detect", s.limit(s.handleDetect))
+	mux.HandleFunc("/batch", s.limit(s.handleBatch))
+	mux.HandleFunc("/reload", s.limit(s.handleReload))
+
+	srv := &http.Server{
+		Addr:         *addr,
+		Handler:      mux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 60 * time.Second,
+	}
+	log.Printf("listening on %s", *addr)
+	log.Fatal(srv.ListenAndServe())
+}
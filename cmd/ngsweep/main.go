@@ -0,0 +1,152 @@
+package main
+
+import (
+	"ebitenprac/ngword"
+	"ebitenprac/ngword/eval"
+	"flag"
+	"fmt"
+	"golang.org/x/text/unicode/norm"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+	"log"
+	"math"
+	"os"
+	"text/tabwriter"
+)
+
+var (
+	dictFile    = flag.String("dict", "resource/ngwords.origin.csv", "NG word dictionary")
+	dataFile    = flag.String("data", "", "labeled CSV of sentences")
+	sentenceCol = flag.String("sentence", "sentence", "column holding the sentences")
+	labelCol    = flag.String("label", "label", "column holding the labels")
+	scoreFile   = flag.String("scores", "", "scoring profile JSON (default built-in profile)")
+	allowFile   = flag.String("allow", "", "allowlist of benign terms, one per line")
+	from        = flag.Float64("from", 0.6, "lowest threshold swept")
+	step        = flag.Float64("step", 0.01, "threshold step")
+	targetFPR   = flag.Float64("target-fpr", 0.01, "false positive rate each word may cause on its own")
+	rocFile     = flag.String("roc", "roc.png", "ROC curve output")
+	prFile      = flag.String("pr", "pr.png", "precision/recall curve output")
+	outFile     = flag.String("write", "", "write the dictionary with the suggested thresholds here")
+)
+
+func savePlot(fname, title, x, y string, lines ...interface{}) error {
+	p, err := plot.New()
+	if err != nil {
+		return err
+	}
+	p.Title.Text = title
+	p.X.Label.Text = x
+	p.Y.Label.Text = y
+	p.X.Min, p.X.Max = 0, 1
+	p.Y.Min, p.Y.Max = 0, 1
+	p.Add(plotter.NewGrid())
+	if err := plotutil.AddLinePoints(p, lines...); err != nil {
+		return err
+	}
+	return p.Save(6*vg.Inch, 6*vg.Inch, fname)
+}
+
+func curves(points []eval.Point) (roc, pr plotter.XYs) {
+	roc = make(plotter.XYs, len(points))
+	pr = make(plotter.XYs, len(points))
+	for i, pt := range points {
+		roc[i].X, roc[i].Y = pt.FPR(), pt.TPR()
+		pr[i].X, pr[i].Y = pt.TPR(), pt.Precision()
+	}
+	return roc, pr
+}
+
+func main() {
+	flag.Parse()
+	if *dataFile == "" {
+		log.Fatal("-data is required")
+	}
+
+	dict, err := ngword.LoadDataframeFromCSV(*dictFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	entries, err := ngword.ParseEntries(dict)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fp, err := os.Open(*dataFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	samples, err := eval.ReadSamples(fp, *sentenceCol, *labelCol)
+	fp.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	la, err := ngword.NewLocalAlignmentTrie(ngword.EntriesFrame(eval.WithoutThresholds(entries)))
+	if err != nil {
+		log.Fatal(err)
+	}
+	la.Fallback = ngword.FixedThreshold(float32(*from))
+	if *scoreFile != "" {
+		sc, err := ngword.LoadScoreProfile(*scoreFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		la.Scorer = sc
+		la.Gap = sc.Affine
+	}
+	if *allowFile != "" {
+		if la.Allow, err = ngword.LoadAllowlist(*allowFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	scored := eval.ScoreSamples(la, samples)
+
+	points := eval.Sweep(scored, eval.Thresholds(*from, *step))
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "threshold\ttpr\tfpr\tprecision\ttp\tfp\ttn\tfn")
+	for _, pt := range points {
+		fmt.Fprintf(tw, "%.2f\t%.4f\t%.4f\t%.4f\t%d\t%d\t%d\t%d\n",
+			pt.Threshold, pt.TPR(), pt.FPR(), pt.Precision(), pt.TP, pt.FP, pt.TN, pt.FN)
+	}
+	tw.Flush()
+
+	current := make(map[string]float64)
+	for _, e := range entries {
+		t := e.Threshold
+		if t <= 0 {
+			t = ngword.LengthThreshold(len([]rune(norm.NFKD.String(e.Word))))
+		}
+		current[e.Word] = float64(t)
+	}
+	suggested := eval.SuggestThresholds(scored, *from, *targetFPR)
+	before := eval.EvaluateThresholds(scored, current, math.Inf(1))
+	after := eval.EvaluateThresholds(scored, suggested, math.Inf(1))
+	fmt.Printf("\ndictionary thresholds: tpr %.4f fpr %.4f precision %.4f\n", before.TPR(), before.FPR(), before.Precision())
+	fmt.Printf("suggested thresholds:  tpr %.4f fpr %.4f precision %.4f\n", after.TPR(), after.FPR(), after.Precision())
+
+	roc, pr := curves(points)
+	if err := savePlot(*rocFile, "ROC", "False positive rate", "True positive rate", "global", roc); err != nil {
+		log.Fatal(err)
+	}
+	if err := savePlot(*prFile, "Precision/Recall", "Recall", "Precision", "global", pr); err != nil {
+		log.Fatal(err)
+	}
+
+	if *outFile == "" {
+		return
+	}
+	for i := range entries {
+		if t, ok := suggested[entries[i].Word]; ok {
+			entries[i].Threshold = float32(t)
+		}
+	}
+	out, err := os.Create(*outFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer out.Close()
+	if err := ngword.EntriesFrame(entries).WriteCSV(out); err != nil {
+		log.Fatal(err)
+	}
+}
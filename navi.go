@@ -1,12 +1,19 @@
 package main
 
 import (
+	"ebitenprac/ngword"
 	"ebitenprac/turi"
 	"github.com/hajimehoshi/ebiten"
 	"image"
 	"image/color"
+	"log"
+	"os"
 )
 
+// pipelineFile configures how both scenes moderate sentences. Without it they
+// mask what LocalAlignmentTrie finds.
+const pipelineFile = "resource/pipeline.json"
+
 type Navi struct {
 	btns         []*turi.Button
 	scenes       map[*turi.Button]turi.Scene
@@ -14,6 +21,7 @@ type Navi struct {
 	rect         image.Rectangle
 	background   *ebiten.Image
 	sceneManager *turi.SceneManager
+	dict         *ngword.DictionaryManager
 }
 
 func NewNavi(rect image.Rectangle) *Navi {
@@ -22,7 +30,15 @@ func NewNavi(rect image.Rectangle) *Navi {
 		scenes:     make(map[*turi.Button]turi.Scene),
 		rect:       rect,
 		background: nil,
+		dict:       ngword.NewDictionaryManager("resource/ngwords.origin.csv"),
+	}
+	if err := navi.dict.Load(); err != nil {
+		log.Print(err)
 	}
+	navi.dict.OnError = func(err error) {
+		log.Print(err)
+	}
+	navi.dict.Start()
 
 	padding := 10
 	width := 100
@@ -35,7 +51,7 @@ func NewNavi(rect image.Rectangle) *Navi {
 		Text: "One sentence",
 		Rect: image.Rect(sx, rect.Min.Y, sx+width, rect.Max.Y),
 	}
-	navi.scenes[b1] = NewUI()
+	navi.scenes[b1] = NewUI(navi.dict)
 	b1.SetOnPressed(func(b *turi.Button) {
 		navi.sceneManager.GoTo(navi.scenes[b])
 	})
@@ -45,7 +61,7 @@ func NewNavi(rect image.Rectangle) *Navi {
 		Text: "Batch",
 		Rect: image.Rect(sx, rect.Min.Y, sx+width, rect.Max.Y),
 	}
-	navi.scenes[b2] = NewBatchScene()
+	navi.scenes[b2] = NewBatchScene(navi.dict)
 	b2.SetOnPressed(func(b *turi.Button) {
 		navi.sceneManager.GoTo(navi.scenes[b])
 	})
@@ -56,9 +72,32 @@ func NewNavi(rect image.Rectangle) *Navi {
 	return navi
 }
 
+// newPipeline builds the pipeline of pipelineFile, or the default one when
+// the file is broken, and keeps it reloaded with dict.
+func newPipeline(dict *ngword.DictionaryManager) (*ngword.Pipeline, error) {
+	cfg := ngword.DefaultPipelineConfig
+	if _, err := os.Stat(pipelineFile); err == nil {
+		if cfg, err = ngword.LoadPipelineConfig(pipelineFile); err != nil {
+			log.Print(err)
+			cfg = ngword.DefaultPipelineConfig
+		}
+	}
+	p, err := cfg.Build(dict.Dictionary())
+	if err != nil {
+		log.Print(err)
+		if p, err = ngword.DefaultPipelineConfig.Build(dict.Dictionary()); err != nil {
+			return nil, err
+		}
+	}
+	if err := dict.Register(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
 func (navi *Navi) Update(input *turi.Input) {
 	if navi.sceneManager == nil {
-		navi.curScene = NewUI()
+		navi.curScene = NewUI(navi.dict)
 		navi.sceneManager = turi.NewSceneManager(screenWidth, screenHeight)
 		navi.sceneManager.GoTo(navi.curScene)
 	}
@@ -0,0 +1,146 @@
+package ngword
+
+import "unicode"
+
+// AhoCorasick is the automaton of a Trie. It finds every word of the trie in
+// one pass over a sentence instead of restarting the walk at every position.
+type AhoCorasick struct {
+	nodes []acNode
+}
+
+type acNode struct {
+	next map[rune]int
+	fail int
+	// dict is the nearest node on the fail chain ending a word, or -1.
+	dict  int
+	depth int
+	end   bool
+	word  string
+	entry *Entry
+}
+
+// ExactMatch is a word found verbatim. Start and End are an inclusive rune
+// range like the positions of SmithWatermanResult.
+type ExactMatch struct {
+	Word       string
+	Start, End int
+	Entry      *Entry
+}
+
+// NewAhoCorasick builds the automaton of the words in t. Later changes to t
+// are not seen by it.
+func NewAhoCorasick(t Trie) *AhoCorasick {
+	ac := &AhoCorasick{nodes: []acNode{{next: make(map[rune]int), dict: -1}}}
+	type item struct {
+		node *TrieNode
+		idx  int
+	}
+	queue := []item{{t.Root, 0}}
+	for len(queue) > 0 {
+		it := queue[0]
+		queue = queue[1:]
+		for r, child := range it.node.Children {
+			parent := ac.nodes[it.idx]
+			n := acNode{
+				next:  make(map[rune]int),
+				depth: parent.depth + 1,
+				end:   child.End,
+				word:  parent.word + string(r),
+				entry: child.Entry,
+			}
+			// nodes are added breadth first, so the fail chain is complete
+			if it.idx != 0 {
+				n.fail = ac.step(parent.fail, r)
+			}
+			if ac.nodes[n.fail].end {
+				n.dict = n.fail
+			} else {
+				n.dict = ac.nodes[n.fail].dict
+			}
+			idx := len(ac.nodes)
+			ac.nodes = append(ac.nodes, n)
+			ac.nodes[it.idx].next[r] = idx
+			queue = append(queue, item{child, idx})
+		}
+	}
+	return ac
+}
+
+func (ac *AhoCorasick) step(s int, r rune) int {
+	for {
+		if next, ok := ac.nodes[s].next[r]; ok {
+			return next
+		}
+		if s == 0 {
+			return 0
+		}
+		s = ac.nodes[s].fail
+	}
+}
+
+// walk calls fn with every match in sentence, ordered by their end, until fn
+// returns false.
+func (ac *AhoCorasick) walk(sentence []rune, fn func(ExactMatch) bool) {
+	s := 0
+	for i, r := range sentence {
+		s = ac.step(s, r)
+		o := s
+		if !ac.nodes[o].end {
+			o = ac.nodes[o].dict
+		}
+		for ; o > 0; o = ac.nodes[o].dict {
+			n := &ac.nodes[o]
+			if !fn(ExactMatch{Word: n.word, Start: i - n.depth + 1, End: i, Entry: n.entry}) {
+				return
+			}
+		}
+	}
+}
+
+// Find returns every occurrence of the words in sentence, overlapping ones
+// included.
+func (ac *AhoCorasick) Find(sentence []rune) []ExactMatch {
+	matches := make([]ExactMatch, 0)
+	ac.walk(sentence, func(m ExactMatch) bool {
+		matches = append(matches, m)
+		return true
+	})
+	return matches
+}
+
+// Contains reports whether any word occurs in sentence.
+func (ac *AhoCorasick) Contains(sentence []rune) bool {
+	found := false
+	ac.walk(sentence, func(ExactMatch) bool {
+		found = true
+		return false
+	})
+	return found
+}
+
+// Replace masks the words in txt with rep like Trie.Replace: case is ignored
+// and so are the spaces and punctuation inside a word.
+func (ac *AhoCorasick) Replace(txt string, rep rune) (string, bool) {
+	words := []rune(txt)
+	// pos holds the positions fed to the automaton, separators inside a
+	// word are skipped
+	pos := make([]int, 0, len(words))
+	replace := false
+	s := 0
+	for i, r := range words {
+		if s != 0 && !isNoneChar(r) {
+			continue
+		}
+		s = ac.step(s, unicode.ToLower(r))
+		pos = append(pos, i)
+		o := s
+		if !ac.nodes[o].end {
+			o = ac.nodes[o].dict
+		}
+		for ; o > 0; o = ac.nodes[o].dict {
+			replaceChars(words, pos[len(pos)-ac.nodes[o].depth], i+1, rep)
+			replace = true
+		}
+	}
+	return string(words), replace
+}
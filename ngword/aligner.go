@@ -0,0 +1,416 @@
+package ngword
+
+import (
+	"context"
+	"golang.org/x/text/unicode/norm"
+	"math"
+	"sync"
+)
+
+// Aligner runs Smith-Waterman synchronously and keeps its matrices between
+// calls, so aligning many sentences allocates little. An Aligner must not be
+// used by several goroutines at once.
+type Aligner struct {
+	Scorer Scorer
+	// Gap switches to affine gap costs when set.
+	Gap *AffineGap
+
+	linear  [][]Node
+	affine  [][]gotohNode
+	word    []rune
+	perfect []int
+	bands   [][]band
+	flat    []flatNode
+	levels  []int
+}
+
+// band is an inclusive range of live columns in a row of the matrix.
+type band struct {
+	lo, hi int
+}
+
+// flatNode is a trie node in the pre-order AlignTrie walks.
+type flatNode struct {
+	node   *TrieNode
+	parent int
+	// size counts the node and its descendants.
+	size   int
+	thresh float32
+	// reach is the most any word below the node, itself included, may
+	// fall short of a perfect match, in matches.
+	reach float32
+}
+
+var noNeed = float32(math.Inf(-1))
+
+func NewAligner(sc Scorer, gap *AffineGap) *Aligner {
+	return &Aligner{Scorer: sc, Gap: gap}
+}
+
+var aligners = sync.Pool{
+	New: func() interface{} {
+		return &Aligner{}
+	},
+}
+
+// getAligner takes a pooled Aligner; put it back with aligners.Put.
+func getAligner(sc Scorer, gap *AffineGap) *Aligner {
+	a := aligners.Get().(*Aligner)
+	a.Scorer, a.Gap = sc, gap
+	return a
+}
+
+// prepare sizes the matrix in use to rows by cols and clears its first row.
+func (a *Aligner) prepare(rows, cols int) {
+	if a.Gap != nil {
+		for len(a.affine) < rows {
+			a.affine = append(a.affine, nil)
+		}
+		for i := 0; i < rows; i++ {
+			if cap(a.affine[i]) < cols {
+				a.affine[i] = make([]gotohNode, cols)
+			}
+			a.affine[i] = a.affine[i][:cols]
+		}
+		for s := range a.affine[0] {
+			a.affine[0][s] = gotohNode{E: minScore, F: minScore}
+		}
+	} else {
+		for len(a.linear) < rows {
+			a.linear = append(a.linear, nil)
+		}
+		for i := 0; i < rows; i++ {
+			if cap(a.linear[i]) < cols {
+				a.linear[i] = make([]Node, cols)
+			}
+			a.linear[i] = a.linear[i][:cols]
+		}
+		for s := range a.linear[0] {
+			a.linear[0][s] = Node{}
+		}
+	}
+	if len(a.word) < rows {
+		a.word = make([]rune, rows)
+		a.perfect = make([]int, rows)
+	}
+	for len(a.bands) < rows {
+		a.bands = append(a.bands, nil)
+	}
+	a.bands[0] = append(a.bands[0][:0], band{0, cols - 1})
+}
+
+// origin resets column 0 of row t, where every alignment may start.
+func (a *Aligner) origin(t int) {
+	if a.Gap != nil {
+		a.affine[t][0] = gotohNode{E: minScore, F: minScore}
+	} else {
+		a.linear[t][0] = Node{}
+	}
+}
+
+// kill marks cell s of row t as pruned.
+func (a *Aligner) kill(t, s int) {
+	if a.Gap != nil {
+		a.affine[t][s] = deadGotoh
+	} else {
+		a.linear[t][s] = Node{Score: minScore}
+	}
+}
+
+// cell computes cell s of row t for the word rune w. Without full the row
+// above is taken as pruned.
+func (a *Aligner) cell(sentence []rune, t, s int, w rune, full bool) {
+	if a.Gap != nil {
+		diag, up := &deadGotoh, &deadGotoh
+		if full {
+			diag, up = &a.affine[t-1][s-1], &a.affine[t-1][s]
+		}
+		gotohCell(a.Scorer, *a.Gap, diag, up, &a.affine[t][s-1], &a.affine[t][s], sentence[s-1], w)
+		return
+	}
+	diag, up := Node{Score: minScore}, Node{Score: minScore}
+	if full {
+		diag, up = a.linear[t-1][s-1], a.linear[t-1][s]
+	}
+	swCell(a.Scorer, diag, up, a.linear[t][s-1], &a.linear[t][s], sentence[s-1], w)
+}
+
+func (a *Aligner) node(t, s int) Node {
+	if a.Gap != nil {
+		n := a.affine[t][s]
+		return Node{Score: n.H, Next: n.Next}
+	}
+	return a.linear[t][s]
+}
+
+func (a *Aligner) history(t, s int) int {
+	if a.Gap != nil {
+		return gotohHistory(a.affine, t, s)
+	}
+	return History(a.linear, t, s)
+}
+
+// fill computes row t, the word rune w, from the live cells of row t-1 and
+// sets the bands of row t to its cells scoring at least need. Cells no live
+// cell reaches are left as they are; their neighbours are computed or
+// killed, so reading one band to the side of a live cell is always safe.
+func (a *Aligner) fill(sentence []rune, t int, w rune, need float32) {
+	n := len(sentence)
+	prev, out := a.bands[t-1], a.bands[t][:0]
+	mark := func(s int) {
+		if float32(a.node(t, s).Score) < need {
+			return
+		}
+		if len(out) > 0 && out[len(out)-1].hi == s-1 {
+			out[len(out)-1].hi = s
+		} else {
+			out = append(out, band{s, s})
+		}
+	}
+
+	a.origin(t)
+	mark(0)
+	last := 0
+	for i := 0; i < len(prev); {
+		// a live cell reaches the cells below and below right of it;
+		// bands whose reach touches are computed as one
+		lo, hi := prev[i].lo, prev[i].hi+1
+		for i++; i < len(prev) && prev[i].lo <= hi+1; i++ {
+			hi = prev[i].hi + 1
+		}
+		if lo <= last {
+			lo = last + 1
+		}
+		if hi > n {
+			hi = n
+		}
+		if lo <= hi {
+			if lo-1 > last {
+				a.kill(t, lo-1)
+			}
+			for s := lo; s <= hi; s++ {
+				a.cell(sentence, t, s, w, true)
+				mark(s)
+			}
+			last = hi
+		}
+
+		// live cells also reach right along the row
+		next := n + 1
+		if i < len(prev) {
+			next = prev[i].lo
+		}
+		for last < n && last+1 < next && float32(a.node(t, last).Score) >= need {
+			last++
+			a.cell(sentence, t, last, w, false)
+			mark(last)
+		}
+	}
+	a.bands[t] = out
+}
+
+// wordEnd walks the alignment ending at cell s of row t back over the
+// sentence runes it skips at its end and returns the cell of the last rune
+// aligned to the word.
+func (a *Aligner) wordEnd(t, s int) int {
+	if a.Gap != nil {
+		for a.affine[t][s].Next == NEXT_J {
+			for extended := true; extended; s-- {
+				extended = a.affine[t][s].EExtended
+			}
+		}
+		return s
+	}
+	for a.linear[t][s].Next == NEXT_J {
+		s--
+	}
+	return s
+}
+
+// best returns the live cell of row t scoring the most from cell i, in band
+// b, back to just after cell s, where the alignment ending at i starts. The
+// rightmost cell above a threshold may run on past the best one, and at a
+// low threshold far past it; the best one scores the same at any threshold.
+func (a *Aligner) best(t, b, i, s int) int {
+	best := i
+	for ; b >= 0 && a.bands[t][b].hi > s; b-- {
+		hi := a.bands[t][b].hi
+		if hi > i {
+			hi = i
+		}
+		for j := hi; j > s && j >= a.bands[t][b].lo; j-- {
+			if a.node(t, j).Score > a.node(t, best).Score {
+				best = j
+			}
+		}
+	}
+	return best
+}
+
+// collect appends the alignments of row lenWord scoring above r.Threshold to
+// results, walking back from the end of the sentence, each reported at its
+// best cell. inclusive also takes the ones scoring exactly the threshold.
+func (a *Aligner) collect(results []SmithWatermanResult, r SmithWatermanResult, lenWord int, inclusive bool) []SmithWatermanResult {
+	threshAgreement := float32(r.CompleteAgreement) * r.Threshold
+	bands := a.bands[lenWord]
+	next := math.MaxInt32
+	for b := len(bands) - 1; b >= 0; b-- {
+		hi := bands[b].hi
+		if hi > next {
+			hi = next
+		}
+		for i := hi; i >= bands[b].lo; i-- {
+			v := a.node(lenWord, i)
+			if float32(v.Score) > threshAgreement || inclusive && float32(v.Score) == threshAgreement {
+				end := a.best(lenWord, b, i, a.history(lenWord, i))
+				s := a.history(lenWord, end)
+				r.AppliedAgreement = a.node(lenWord, end).Score
+				r.SimilarScore = float32(r.AppliedAgreement) / float32(r.CompleteAgreement)
+				r.StartPos = s
+				r.EndPos = end - 1
+				r.WordEnd = a.wordEnd(lenWord, end) - 1
+				results = append(results, r)
+				i, next = s, s-1
+			}
+		}
+	}
+	return results
+}
+
+// Align is the synchronous SmithWaterman. It returns the hits of word in
+// sentence, last one first, and the final row of the matrix.
+func (a *Aligner) Align(ctx context.Context, sentence, word []rune, thresh float32) ([]SmithWatermanResult, SmithWatermanEnd, error) {
+	lenStc := len(sentence)
+	lenWord := len(word)
+	a.prepare(lenWord+1, lenStc+1)
+	for t := 1; t <= lenWord; t++ {
+		if err := ctx.Err(); err != nil {
+			return nil, SmithWatermanEnd{}, err
+		}
+		a.fill(sentence, t, word[t-1], noNeed)
+	}
+
+	completeAgreement := perfectScore(a.Scorer, word)
+	maxAgreement := minScore
+	lastNodes := make([]Node, lenStc+1)
+	for i := range lastNodes {
+		lastNodes[i] = a.node(lenWord, i)
+		if lastNodes[i].Score > maxAgreement {
+			maxAgreement = lastNodes[i].Score
+		}
+	}
+	results := a.collect(make([]SmithWatermanResult, 0), SmithWatermanResult{
+		MatchWord:         string(word),
+		CompleteAgreement: completeAgreement,
+		Threshold:         thresh,
+	}, lenWord, false)
+
+	return results, SmithWatermanEnd{
+		MatchWord:         norm.NFC.String(string(word)),
+		LastNodes:         lastNodes,
+		CompleteAgreement: completeAgreement,
+		MaxAgreement:      maxAgreement,
+		ThreshAgreement:   int(float32(completeAgreement) * thresh),
+	}, nil
+}
+
+// flatten lays words out in pre-order and works out the threshold of every
+// word and how far below a perfect match each subtree may still report.
+// It returns the depth of the trie.
+func (a *Aligner) flatten(words Trie, fallback Threshold) int {
+	a.flat = a.flat[:0]
+	depth := 0
+	words.Walk(func(node *TrieNode) bool {
+		for len(a.levels) <= node.Level {
+			a.levels = append(a.levels, 0)
+		}
+		parent := -1
+		if node.Level > 1 {
+			parent = a.levels[node.Level-1]
+		}
+		a.levels[node.Level] = len(a.flat)
+		a.flat = append(a.flat, flatNode{node: node, parent: parent, size: 1, reach: noNeed})
+		if node.Level > depth {
+			depth = node.Level
+		}
+		return true
+	})
+
+	for i := len(a.flat) - 1; i >= 0; i-- {
+		f := &a.flat[i]
+		if f.node.End {
+			f.thresh = fallback(f.node.Level)
+			if f.node.Entry != nil && f.node.Entry.Threshold > 0 {
+				f.thresh = f.node.Entry.Threshold
+			}
+			if reach := (1 - f.thresh) * float32(f.node.Level); reach > f.reach {
+				f.reach = reach
+			}
+		}
+		if f.parent >= 0 {
+			p := &a.flat[f.parent]
+			p.size += f.size
+			if f.reach > p.reach {
+				p.reach = f.reach
+			}
+		}
+	}
+	return depth
+}
+
+// AlignTrie is the synchronous SmithWatermanTrie. Words sharing a prefix
+// share the rows of the prefix. When the scorer is a ScoreProfile that never
+// rewards a step above Match, cells too far behind to reach the threshold of
+// any word below are skipped, and so are subtrees left without such cells;
+// the hits stay the same while long sentences cost far less.
+func (a *Aligner) AlignTrie(ctx context.Context, sentence []rune, words Trie, fallback Threshold) ([]SmithWatermanResult, error) {
+	return a.alignFlat(ctx, sentence, a.flatten(words, fallback))
+}
+
+// alignFlat is AlignTrie over the words last flattened, depth being the
+// depth flatten returned.
+func (a *Aligner) alignFlat(ctx context.Context, sentence []rune, depth int) ([]SmithWatermanResult, error) {
+	match := -1
+	if p, ok := a.Scorer.(*ScoreProfile); ok && p.bounded(a.Gap) {
+		match = p.Match
+	}
+	a.prepare(depth+1, len(sentence)+1)
+
+	results := make([]SmithWatermanResult, 0)
+	for i := 0; i < len(a.flat); {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		f := &a.flat[i]
+		node, t := f.node, f.node.Level
+		a.word[t-1] = node.Value
+		a.perfect[t] = a.perfect[t-1] + a.Scorer.Score(node.Value, node.Value)
+
+		need := noNeed
+		if match >= 0 {
+			// a cell gains at most match per row left, the slack is
+			// taken off and one more keeps float rounding on the safe side
+			need = float32(a.perfect[t]) - float32(match)*f.reach - 1
+		}
+		a.fill(sentence, t, node.Value, need)
+
+		if node.End {
+			r := SmithWatermanResult{
+				MatchWord:         norm.NFKC.String(string(a.word[:t])),
+				CompleteAgreement: a.perfect[t],
+				Threshold:         f.thresh,
+				Entry:             node.Entry,
+			}
+			if node.Entry != nil {
+				r.MatchWord = node.Entry.Word
+			}
+			results = a.collect(results, r, t, true)
+		}
+		if len(a.bands[t]) == 0 {
+			i += f.size
+		} else {
+			i++
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,100 @@
+package ngword
+
+import (
+	"context"
+	"golang.org/x/text/unicode/norm"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// unbounded hides the ScoreProfile behind it, which turns off the pruning
+// of the trie walk.
+type unbounded struct {
+	Scorer
+}
+
+func entryTrie(entries []Entry) Trie {
+	trie := NewTrie()
+	for i := range entries {
+		trie.AppendEntry(norm.NFKD.String(entries[i].Word), &entries[i])
+	}
+	return trie
+}
+
+// sortResults orders results by position, as the trie walk visits words in
+// no set order.
+func sortResults(results []SmithWatermanResult) {
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.StartPos != b.StartPos {
+			return a.StartPos < b.StartPos
+		}
+		if a.EndPos != b.EndPos {
+			return a.EndPos < b.EndPos
+		}
+		return a.MatchWord < b.MatchWord
+	})
+}
+
+func TestAlignTriePruningKeepsHits(t *testing.T) {
+	entries := loadEntries(t)
+	trie := entryTrie(entries)
+	stcs := append(randomSentences(entries, 1500, 16, 3), samples...)
+	for _, gap := range []*AffineGap{nil, &DefaultAffineGap} {
+		for _, fallback := range []Threshold{LengthThreshold, FixedThreshold(0.5)} {
+			pruned := NewAligner(DefaultScorer, gap)
+			full := NewAligner(unbounded{DefaultScorer}, gap)
+			for _, s := range stcs {
+				runes := NewText(s).Runes
+				want, err := full.AlignTrie(context.Background(), runes, trie, fallback)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := pruned.AlignTrie(context.Background(), runes, trie, fallback)
+				if err != nil {
+					t.Fatal(err)
+				}
+				sortResults(want)
+				sortResults(got)
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("affine %v, %q: pruned gives %v, unpruned %v", gap != nil, s, got, want)
+				}
+			}
+		}
+	}
+}
+
+func benchAlignTrie(b *testing.B, sentences []string) {
+	trie := entryTrie(loadEntries(b))
+	runes := make([][]rune, len(sentences))
+	for i, s := range sentences {
+		runes[i] = NewText(s).Runes
+	}
+	for _, c := range []struct {
+		name string
+		a    *Aligner
+	}{
+		{"pruned", NewAligner(DefaultScorer, nil)},
+		{"unpruned", NewAligner(unbounded{DefaultScorer}, nil)},
+		{"affine", NewAligner(DefaultScorer, &DefaultAffineGap)},
+		{"affine+unpruned", NewAligner(unbounded{DefaultScorer}, &DefaultAffineGap)},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				c.a.AlignTrie(context.Background(), runes[i%len(runes)], trie, LengthThreshold)
+			}
+		})
+	}
+}
+
+// BenchmarkAlignTrie aligns one sample sentence per op.
+func BenchmarkAlignTrie(b *testing.B) {
+	benchAlignTrie(b, samples)
+}
+
+// BenchmarkAlignTrieLong aligns an input of 10k runes per op.
+func BenchmarkAlignTrieLong(b *testing.B) {
+	benchAlignTrie(b, []string{long(samples, 10000)})
+}
@@ -0,0 +1,119 @@
+package ngword
+
+import (
+	"bufio"
+	"golang.org/x/text/unicode/norm"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Allowlist holds benign terms that happen to align with NG words, such as
+// place names. A match lying entirely inside an occurrence of one of them is
+// suppressed.
+type Allowlist struct {
+	Terms []string
+}
+
+func NewAllowlist(terms ...string) *Allowlist {
+	a := &Allowlist{}
+	for _, t := range terms {
+		if t = strings.TrimSpace(t); t != "" {
+			a.Terms = append(a.Terms, t)
+		}
+	}
+	return a
+}
+
+// LoadAllowlist reads one term per line. Empty lines and lines starting with
+// # are skipped.
+func LoadAllowlist(fname string) (*Allowlist, error) {
+	fp, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	terms := make([]string, 0)
+	sc := bufio.NewScanner(fp)
+	for sc.Scan() {
+		if line := sc.Text(); !strings.HasPrefix(line, "#") {
+			terms = append(terms, line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return NewAllowlist(terms...), nil
+}
+
+// Covering returns the first term that covers sp in sentence. Spaces at
+// either end of sp are ignored.
+func (a *Allowlist) Covering(sentence string, sp Span) (string, bool) {
+	if a == nil {
+		return "", false
+	}
+	return covering(a.Terms, sentence, sp)
+}
+
+func covering(terms []string, sentence string, sp Span) (string, bool) {
+	sp = trimSpan(sentence, sp)
+	for _, t := range terms {
+		for _, form := range []string{t, norm.NFC.String(t), norm.NFD.String(t)} {
+			if coveredBy(sentence, form, sp) {
+				return t, true
+			}
+		}
+	}
+	return "", false
+}
+
+func coveredBy(sentence, term string, sp Span) bool {
+	if term == "" {
+		return false
+	}
+	// an occurrence covering sp starts at most len(term) bytes before it
+	from := sp.End - len(term)
+	if from < 0 {
+		from = 0
+	}
+	for from <= sp.Start {
+		i := strings.Index(sentence[from:], term)
+		if i < 0 {
+			return false
+		}
+		start := from + i
+		if start > sp.Start {
+			return false
+		}
+		if start+len(term) >= sp.End {
+			return true
+		}
+		from = start + 1
+	}
+	return false
+}
+
+func trimSpan(s string, sp Span) Span {
+	return trimSpanFunc(s, sp, unicode.IsSpace)
+}
+
+// trimSpanFunc trims the runes satisfying f off both ends of sp.
+func trimSpanFunc(s string, sp Span, f func(rune) bool) Span {
+	for sp.Start < sp.End {
+		r, n := utf8.DecodeRuneInString(s[sp.Start:sp.End])
+		if !f(r) {
+			break
+		}
+		sp.Start += n
+	}
+	for sp.Start < sp.End {
+		r, n := utf8.DecodeLastRuneInString(s[sp.Start:sp.End])
+		if !f(r) {
+			break
+		}
+		sp.End -= n
+	}
+	return sp
+}
@@ -0,0 +1,77 @@
+package ngword
+
+import (
+	"context"
+	"github.com/go-gota/gota/dataframe"
+)
+
+// Detector reports the NG words of a sentence. LocalAlignmentTrie and
+// Romanized are Detectors.
+type Detector interface {
+	DetectContext(ctx context.Context, sentence string) ([]Match, error)
+}
+
+// Combined runs several Detectors over every sentence and masks what any of
+// them found.
+type Combined struct {
+	Detectors []Detector
+}
+
+func NewCombined(detectors ...Detector) *Combined {
+	return &Combined{Detectors: detectors}
+}
+
+// Reload passes df on to the Detectors that are Reloaders.
+func (c *Combined) Reload(df dataframe.DataFrame) error {
+	for _, d := range c.Detectors {
+		if r, ok := d.(Reloader); ok {
+			if err := r.Reload(df); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Combined) Replace(sentence string) (string, bool) {
+	replaced, changed, _ := c.ReplaceContext(context.Background(), sentence)
+	return replaced, changed
+}
+
+// ReplaceContext is Replace giving up with the error of ctx once it is done.
+func (c *Combined) ReplaceContext(ctx context.Context, sentence string) (string, bool, error) {
+	return replaced(c.DecideContext(ctx, sentence))
+}
+
+// DecideContext is ReplaceContext telling the matches and the Toxicity too.
+func (c *Combined) DecideContext(ctx context.Context, sentence string) (Decision, error) {
+	matches, err := c.DetectContext(ctx, sentence)
+	if err != nil {
+		return Decision{Filtered: sentence}, err
+	}
+	return maskDecision(sentence, NewText(sentence).Mask(Spans(matches), '*'), matches), nil
+}
+
+func (c *Combined) Detect(sentence string) []Match {
+	matches, _ := c.DetectContext(context.Background(), sentence)
+	return matches
+}
+
+// DetectContext is Detect giving up with the error of ctx once it is done. A
+// word found by several Detectors at the same place is reported once, by the
+// first of them.
+func (c *Combined) DetectContext(ctx context.Context, sentence string) ([]Match, error) {
+	matches := make([]Match, 0)
+	for _, d := range c.Detectors {
+		found, err := d.DetectContext(ctx, sentence)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range found {
+			if !hasMatch(matches, m) {
+				matches = append(matches, m)
+			}
+		}
+	}
+	return matches, nil
+}
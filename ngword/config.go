@@ -0,0 +1,146 @@
+package ngword
+
+import (
+	"flag"
+	"fmt"
+	"github.com/go-gota/gota/dataframe"
+)
+
+// FilterNames are the filters a FilterConfig builds.
+var FilterNames = []string{"la", "trie", "perfect", "replace", "aho", "romanized"}
+
+// FilterConfig sets up the filter of a command from its flags, so that every
+// command builds the same filter for the same flags.
+type FilterConfig struct {
+	// Filter is one of FilterNames.
+	Filter string
+	// Scores is a scoring profile file, the built-in profile if empty.
+	Scores string
+	// Normalize is a NormalizeConfig file for the la and trie filters.
+	Normalize string
+	// Allow is an allowlist file.
+	Allow string
+	// Prefilter, Dubeolsik and Romanized set up the trie filter.
+	Prefilter bool
+	Dubeolsik bool
+	Romanized bool
+	// Pipeline is a PipelineConfig file replacing Filter and the options
+	// above.
+	Pipeline string
+}
+
+// matcherFlags are the flags of the options Pipeline replaces.
+var matcherFlags = []string{"scores", "normalize", "allow", "prefilter", "dubeolsik", "romanized"}
+
+// RegisterFlags defines the flags of every field but Filter in fs, which each
+// command names its own way.
+func (c *FilterConfig) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Scores, "scores", "", "scoring profile JSON (default built-in profile)")
+	fs.StringVar(&c.Normalize, "normalize", "", "normalization pipeline JSON, such as resource/normalize.default.json (la and trie filters)")
+	fs.StringVar(&c.Allow, "allow", "", "allowlist of benign terms, one per line")
+	fs.BoolVar(&c.Prefilter, "prefilter", false, "skip the alignment of the NG words written verbatim (trie filter)")
+	fs.BoolVar(&c.Dubeolsik, "dubeolsik", false, "also search Latin letters read as Hangul typed on a Dubeolsik keyboard (trie filter)")
+	fs.BoolVar(&c.Romanized, "romanized", false, "also search romanized Korean such as \"ssibal\" (trie filter)")
+	fs.StringVar(&c.Pipeline, "pipeline", "", "pipeline config JSON, such as resource/pipeline.example.json; replaces the filter and its flags")
+}
+
+// CheckFlags fails when a flag of fs that Pipeline replaces is set along with
+// it, rather than ignoring the flag.
+func (c *FilterConfig) CheckFlags(fs *flag.FlagSet) error {
+	if c.Pipeline == "" {
+		return nil
+	}
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		for _, name := range matcherFlags {
+			if f.Name == name && err == nil {
+				err = fmt.Errorf("ngword: -%s does not apply with -pipeline; set it in %s", name, c.Pipeline)
+			}
+		}
+	})
+	return err
+}
+
+// PipelineConfig is the pipeline of the trie filter: a LocalAlignmentTrie,
+// with Romanized behind it if set, masking every NG word.
+func (c FilterConfig) PipelineConfig() (PipelineConfig, error) {
+	pc := PipelineConfig{
+		Fuzzy:  []FuzzyConfig{{Type: "trie", Scores: c.Scores, Prefilter: c.Prefilter}},
+		Policy: DefaultPolicy,
+	}
+	if c.Romanized {
+		pc.Fuzzy = append(pc.Fuzzy, FuzzyConfig{Type: "romanized"})
+	}
+	if c.Dubeolsik {
+		pc.Variants = []string{DubeolsikVariant.Name}
+	}
+	if c.Allow != "" {
+		pc.Allow = []string{c.Allow}
+	}
+	if c.Normalize != "" {
+		nc, err := LoadNormalizeConfig(c.Normalize)
+		if err != nil {
+			return pc, err
+		}
+		pc.Normalize = &nc
+	}
+	return pc, nil
+}
+
+// Build creates the filter of c with the dictionary df: the pipeline of
+// Pipeline if set, of PipelineConfig for the trie filter.
+func (c FilterConfig) Build(df dataframe.DataFrame) (Filter, error) {
+	if c.Pipeline != "" {
+		pc, err := LoadPipelineConfig(c.Pipeline)
+		if err != nil {
+			return nil, err
+		}
+		return pc.Build(df)
+	}
+	switch c.Filter {
+	case "trie":
+		pc, err := c.PipelineConfig()
+		if err != nil {
+			return nil, err
+		}
+		return pc.Build(df)
+	case "la":
+		la, err := NewLocalAlignment(df)
+		if err != nil {
+			return nil, err
+		}
+		if c.Scores != "" {
+			sc, err := LoadScoreProfile(c.Scores)
+			if err != nil {
+				return nil, err
+			}
+			la.Scorer, la.Gap = sc, sc.Affine
+		}
+		if c.Normalize != "" {
+			nz, err := LoadNormalization(c.Normalize)
+			if err != nil {
+				return nil, err
+			}
+			la.SetNormalization(nz)
+		}
+		return la, nil
+	case "romanized":
+		ro, err := NewRomanized(df)
+		if err != nil {
+			return nil, err
+		}
+		if c.Allow != "" {
+			if ro.Allow, err = LoadAllowlist(c.Allow); err != nil {
+				return nil, err
+			}
+		}
+		return ro, nil
+	case "perfect":
+		return NewPerfectMatch(df)
+	case "replace":
+		return NewTrieReplace(df)
+	case "aho":
+		return NewAhoCorasickReplace(df)
+	}
+	return nil, fmt.Errorf("ngword: unknown filter %q", c.Filter)
+}
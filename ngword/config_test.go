@@ -0,0 +1,46 @@
+package ngword
+
+import (
+	"flag"
+	"io"
+	"testing"
+)
+
+func TestFilterConfigCheckFlags(t *testing.T) {
+	for _, c := range []struct {
+		args []string
+		ok   bool
+	}{
+		{[]string{"-prefilter", "-romanized"}, true},
+		{[]string{"-pipeline", "p.json"}, true},
+		{[]string{"-pipeline", "p.json", "-prefilter"}, false},
+		{[]string{"-scores", "s.json", "-pipeline", "p.json"}, false},
+	} {
+		var cfg FilterConfig
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		cfg.RegisterFlags(fs)
+		if err := fs.Parse(c.args); err != nil {
+			t.Fatal(err)
+		}
+		if err := cfg.CheckFlags(fs); (err == nil) != c.ok {
+			t.Errorf("%v: got %v", c.args, err)
+		}
+	}
+}
+
+func TestFilterConfigBuild(t *testing.T) {
+	df := ReadDataframeFromCSV("../resource/ngwords.new.plain.csv")
+	for _, name := range FilterNames {
+		f, err := FilterConfig{Filter: name}.Build(df)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got, changed := f.Replace("씨발 ssibal"); !changed || got == "씨발 ssibal" {
+			t.Errorf("%s: got %q, %v", name, got, changed)
+		}
+	}
+	if _, err := (FilterConfig{Filter: "nope"}).Build(df); err == nil {
+		t.Error("built an unknown filter")
+	}
+}
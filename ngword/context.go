@@ -0,0 +1,152 @@
+package ngword
+
+import (
+	"fmt"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ContextRule constrains the original text around a dictionary word. Rules
+// are written in the context column of the dictionary, separated by "|":
+//
+//	left:X    X must come right before the word
+//	right:X   X must come right after the word
+//	start     the word must begin a word of the sentence
+//	end       the word must end a word of the sentence
+//	word      both start and end
+//
+// and a leading "!" forbids instead, so "!right:점" drops 시발 in 시발점. Text
+// is compared as written, spaces included, and several required left or right
+// texts are alternatives: "right:은|right:는" accepts either particle.
+type ContextRule struct {
+	Forbid bool
+	// Side is "left", "right", "start", "end" or "word".
+	Side string
+	Text string
+}
+
+func ParseContextRule(s string) (ContextRule, error) {
+	var r ContextRule
+	if strings.HasPrefix(s, "!") {
+		r.Forbid, s = true, s[1:]
+	}
+	r.Side = s
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		r.Side, r.Text = s[:i], norm.NFC.String(s[i+1:])
+	}
+	switch r.Side {
+	case "left", "right":
+		if r.Text == "" {
+			return r, fmt.Errorf("ngword: context rule %q has no text", s)
+		}
+	case "start", "end", "word":
+		if r.Text != "" {
+			return r, fmt.Errorf("ngword: context rule %q takes no text", s)
+		}
+	default:
+		return r, fmt.Errorf("ngword: unknown context rule %q", s)
+	}
+	return r, nil
+}
+
+func (r ContextRule) String() string {
+	s := r.Side
+	if r.Text != "" {
+		s += ":" + r.Text
+	}
+	if r.Forbid {
+		s = "!" + s
+	}
+	return s
+}
+
+// holds reports whether r is true of the word s[start:end], ignoring Forbid.
+func (r ContextRule) holds(s string, start, end int) bool {
+	switch r.Side {
+	case "left":
+		return strings.HasSuffix(norm.NFC.String(s[:start]), r.Text)
+	case "right":
+		return strings.HasPrefix(norm.NFC.String(s[end:]), r.Text)
+	case "start":
+		return startsWord(s, start)
+	case "end":
+		return endsWord(s, end)
+	case "word":
+		return startsWord(s, start) && endsWord(s, end)
+	}
+	return false
+}
+
+// checkContext returns the first of rules the match sp of sentence breaks.
+// The marks and spaces alignments take in at the ends of sp are trimmed
+// first.
+func checkContext(rules []ContextRule, sentence string, sp Span) (ContextRule, bool) {
+	if len(rules) == 0 {
+		return ContextRule{}, true
+	}
+	start, end := trimWord(sentence, sp.Start, sp.End)
+	var left, right []ContextRule
+	for _, r := range rules {
+		switch {
+		case r.Forbid:
+			if r.holds(sentence, start, end) {
+				return r, false
+			}
+		case r.Side == "left":
+			left = append(left, r)
+		case r.Side == "right":
+			right = append(right, r)
+		default:
+			if !r.holds(sentence, start, end) {
+				return r, false
+			}
+		}
+	}
+	for _, alts := range [][]ContextRule{left, right} {
+		if len(alts) > 0 && !anyHolds(alts, sentence, start, end) {
+			return alts[0], false
+		}
+	}
+	return ContextRule{}, true
+}
+
+func anyHolds(rules []ContextRule, s string, start, end int) bool {
+	for _, r := range rules {
+		if r.holds(s, start, end) {
+			return true
+		}
+	}
+	return false
+}
+
+// trimWord trims the runes that are not letters off both ends of
+// s[start:end].
+func trimWord(s string, start, end int) (int, int) {
+	for start < end {
+		r, n := utf8.DecodeRuneInString(s[start:end])
+		if unicode.IsLetter(r) {
+			break
+		}
+		start += n
+	}
+	for end > start {
+		r, n := utf8.DecodeLastRuneInString(s[start:end])
+		if unicode.IsLetter(r) {
+			break
+		}
+		end -= n
+	}
+	return start, end
+}
+
+func startsWord(s string, start int) bool {
+	before, _ := utf8.DecodeLastRuneInString(s[:start])
+	return start == 0 || !unicode.IsLetter(before)
+}
+
+func endsWord(s string, end int) bool {
+	after, _ := utf8.DecodeRuneInString(s[end:])
+	return end == len(s) || !unicode.IsLetter(after)
+}
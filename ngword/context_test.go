@@ -0,0 +1,49 @@
+package ngword
+
+import (
+	"github.com/go-gota/gota/dataframe"
+	"strings"
+	"testing"
+)
+
+// TestContextUsesWordEnd checks the rules against where the word ends,
+// although alignments often run on over the rune after it.
+func TestContextUsesWordEnd(t *testing.T) {
+	df := dataframe.ReadCSV(strings.NewReader("word,threshold,context\n병신,90,!right:아\n씨발,90,word\n"),
+		dataframe.DetectTypes(false))
+	for _, gap := range []*AffineGap{nil, &DefaultAffineGap} {
+		la, err := NewLocalAlignmentTrie(df)
+		if err != nil {
+			t.Fatal(err)
+		}
+		la.Gap = gap
+		for _, c := range []struct {
+			sentence, word string
+			suppressed     bool
+		}{
+			{"병신아", "병신", true},
+			{"야 병신아 뭐해", "병신", true},
+			{"병신 아니야", "병신", false},
+			{"병신", "병신", false},
+			{"씨발놈", "씨발", true},
+			{"씨발놈아", "씨발", true},
+			{"씨발 놈아", "씨발", false},
+			{"야 씨발", "씨발", false},
+		} {
+			found := false
+			for _, m := range la.Detect(c.sentence) {
+				if m.Word != c.word {
+					continue
+				}
+				found = true
+				if m.Suppressed != c.suppressed {
+					t.Errorf("affine %v, %q: %s [%d,%d) suppressed %v, want %v",
+						gap != nil, c.sentence, m.Word, m.Start, m.End, m.Suppressed, c.suppressed)
+				}
+			}
+			if !found {
+				t.Errorf("affine %v, %q: %s not found", gap != nil, c.sentence, c.word)
+			}
+		}
+	}
+}
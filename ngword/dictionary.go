@@ -0,0 +1,161 @@
+package ngword
+
+import (
+	"github.com/go-gota/gota/dataframe"
+	"github.com/go-gota/gota/series"
+	"os"
+	"sync"
+	"time"
+)
+
+// Reloader is a filter whose dictionary can be swapped while it is in use.
+type Reloader interface {
+	Reload(df dataframe.DataFrame) error
+}
+
+// DictionaryManager polls a dictionary CSV and reloads the registered filters
+// whenever the file changes. A dictionary that fails to load is reported and
+// the filters keep the last good one.
+type DictionaryManager struct {
+	Path     string
+	Interval time.Duration
+	// OnError is called from the polling goroutine when a reload fails.
+	OnError func(err error)
+
+	mu      sync.Mutex
+	df      dataframe.DataFrame
+	filters []Reloader
+	modTime time.Time
+	size    int64
+	err     error
+	stop    chan struct{}
+}
+
+func NewDictionaryManager(path string) *DictionaryManager {
+	return &DictionaryManager{
+		Path:     path,
+		Interval: 2 * time.Second,
+		df:       dataframe.New(series.New([]string{}, series.String, "word")),
+	}
+}
+
+// Load reads the dictionary now and swaps it into every registered filter.
+func (m *DictionaryManager) Load() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.load()
+}
+
+func (m *DictionaryManager) load() error {
+	fi, err := os.Stat(m.Path)
+	if err == nil {
+		m.modTime, m.size = fi.ModTime(), fi.Size()
+		var df dataframe.DataFrame
+		df, err = LoadDataframeFromCSV(m.Path)
+		if err == nil {
+			_, err = ParseEntries(df)
+		}
+		if err == nil {
+			err = m.reload(df)
+		}
+	}
+	m.err = err
+	return err
+}
+
+// reload swaps df into every filter and makes it the dictionary. When a
+// filter fails, the ones already reloaded go back to the last good
+// dictionary.
+func (m *DictionaryManager) reload(df dataframe.DataFrame) error {
+	for i, f := range m.filters {
+		if err := f.Reload(df); err != nil {
+			for _, g := range m.filters[:i] {
+				g.Reload(m.df)
+			}
+			return err
+		}
+	}
+	m.df = df
+	return nil
+}
+
+// Dictionary returns the last dictionary loaded successfully, or an empty
+// one if none has been.
+func (m *DictionaryManager) Dictionary() dataframe.DataFrame {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.df
+}
+
+// Err returns the error of the last load, nil if it succeeded.
+func (m *DictionaryManager) Err() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.err
+}
+
+// Register adds filters to be reloaded on change and loads the current
+// dictionary into them.
+func (m *DictionaryManager) Register(filters ...Reloader) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.filters = append(m.filters, filters...)
+	for _, f := range filters {
+		if err := f.Reload(m.df); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Start polls the file every Interval until Stop is called.
+func (m *DictionaryManager) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stop != nil {
+		return
+	}
+	m.stop = make(chan struct{})
+	go m.watch(m.stop, m.Interval)
+}
+
+func (m *DictionaryManager) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+}
+
+func (m *DictionaryManager) watch(stop <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := m.poll(); err != nil && m.OnError != nil {
+				m.OnError(err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (m *DictionaryManager) poll() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fi, err := os.Stat(m.Path)
+	if err != nil {
+		if m.err == nil {
+			m.err = err
+			return err
+		}
+		return nil
+	}
+	if fi.ModTime().Equal(m.modTime) && fi.Size() == m.size {
+		return nil
+	}
+	return m.load()
+}
@@ -0,0 +1,87 @@
+package ngword
+
+import (
+	"errors"
+	"github.com/go-gota/gota/dataframe"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// recorder is a Reloader remembering the words it was last given, failing
+// on the dictionaries holding fail.
+type recorder struct {
+	words []string
+	fail  string
+}
+
+func (r *recorder) Reload(df dataframe.DataFrame) error {
+	words := df.Col("word").Records()
+	for _, w := range words {
+		if w == r.fail {
+			return errors.New("refused " + w)
+		}
+	}
+	r.words = words
+	return nil
+}
+
+func writeDict(t *testing.T, fname, csv string) {
+	t.Helper()
+	if err := os.WriteFile(fname, []byte(csv), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func dictWords(m *DictionaryManager) []string {
+	return m.Dictionary().Col("word").Records()
+}
+
+func TestDictionaryManagerKeepsLastGood(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "dict.csv")
+	writeDict(t, fname, "word,threshold\n씨발,0.8\n")
+	m := NewDictionaryManager(fname)
+	if err := m.Load(); err != nil {
+		t.Fatal(err)
+	}
+	a, b := &recorder{}, &recorder{fail: "개새끼"}
+	if err := m.Register(a, b); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.words) != 1 || len(b.words) != 1 {
+		t.Fatalf("Register loaded %v and %v, want the current dictionary", a.words, b.words)
+	}
+
+	// a broken file reaches no filter
+	writeDict(t, fname, "threshold\n0.8\n")
+	if err := m.Load(); err == nil {
+		t.Fatal("loaded a dictionary without a word column")
+	}
+	if m.Err() == nil {
+		t.Error("Err is nil after a failed load")
+	}
+
+	// a filter refusing the new words puts the others back
+	writeDict(t, fname, "word,threshold\n씨발,0.8\n개새끼,0.8\n")
+	if err := m.Load(); err == nil {
+		t.Fatal("the refusal of a filter was not reported")
+	}
+	for _, got := range [][]string{dictWords(m), a.words, b.words} {
+		if len(got) != 1 || got[0] != "씨발" {
+			t.Errorf("got %v after a failed reload, want the last good [씨발]", got)
+		}
+	}
+
+	writeDict(t, fname, "word,threshold\n씨발,0.8\n병신,0.8\n")
+	if err := m.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if m.Err() != nil {
+		t.Errorf("Err is %v after a good load", m.Err())
+	}
+	for _, got := range [][]string{dictWords(m), a.words, b.words} {
+		if len(got) != 2 {
+			t.Errorf("got %v, want [씨발 병신]", got)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package ngword
+
+import (
+	"fmt"
+	"github.com/go-gota/gota/dataframe"
+	"github.com/go-gota/gota/series"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultSeverity = 3
+	MaxSeverity     = 5
+)
+
+// DictionaryColumns is the dictionary schema. Only word is required; the
+// other columns fall back to the defaults described on Entry.
+var DictionaryColumns = []string{"word", "threshold", "category", "severity", "lang", "country", "usage", "allow", "context"}
+
+// Entry is one row of the NG word dictionary.
+type Entry struct {
+	Word string
+	// Threshold is the similarity in [0, 1] the word must reach, given in
+	// percent in the CSV. Zero leaves it to the filter's default.
+	Threshold float32
+	Category  string
+	// Severity ranges from 1 to MaxSeverity, DefaultSeverity if empty.
+	Severity int
+	// Lang, Country and Usage are "all" if empty.
+	Lang, Country, Usage string
+	// Allow lists longer words containing Word that are not NG words,
+	// separated by "|" in the CSV.
+	Allow []string
+	// Context must hold around a match of Word for it to count.
+	Context []ContextRule
+}
+
+// ParseEntries validates df against the dictionary schema.
+func ParseEntries(df dataframe.DataFrame) ([]Entry, error) {
+	if df.Err != nil {
+		return nil, df.Err
+	}
+	if !hasColumn(df, "word") {
+		return nil, fmt.Errorf("ngword: dictionary has no word column")
+	}
+	col := func(name string) []string {
+		if !hasColumn(df, name) {
+			return make([]string, df.Nrow())
+		}
+		return df.Col(name).Records()
+	}
+	words := col("word")
+	threshs := col("threshold")
+	categories := col("category")
+	severities := col("severity")
+	langs := col("lang")
+	countries := col("country")
+	usages := col("usage")
+	allows := col("allow")
+	contexts := col("context")
+
+	entries := make([]Entry, 0, len(words))
+	for i, w := range words {
+		// line 1 holds the header
+		line := i + 2
+		w = strings.TrimSpace(w)
+		if w == "" {
+			return nil, fmt.Errorf("ngword: line %d: empty word", line)
+		}
+		e := Entry{
+			Word:     w,
+			Category: strings.TrimSpace(categories[i]),
+			Severity: DefaultSeverity,
+			Lang:     orAll(langs[i]),
+			Country:  orAll(countries[i]),
+			Usage:    orAll(usages[i]),
+		}
+		if v := cell(threshs[i]); v != "" {
+			f, err := strconv.ParseFloat(v, 32)
+			if err != nil || f < 0 || f > 100 {
+				return nil, fmt.Errorf("ngword: line %d: threshold %q is not a percentage", line, v)
+			}
+			e.Threshold = float32(f) / 100
+		}
+		if v := cell(severities[i]); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > MaxSeverity {
+				return nil, fmt.Errorf("ngword: line %d: severity %q is not between 1 and %d", line, v, MaxSeverity)
+			}
+			e.Severity = n
+		}
+		for _, a := range strings.Split(cell(allows[i]), "|") {
+			if a = strings.TrimSpace(a); a != "" {
+				e.Allow = append(e.Allow, a)
+			}
+		}
+		for _, c := range strings.Split(cell(contexts[i]), "|") {
+			if c = strings.TrimSpace(c); c == "" {
+				continue
+			}
+			rule, err := ParseContextRule(c)
+			if err != nil {
+				return nil, fmt.Errorf("ngword: line %d: %v", line, err)
+			}
+			e.Context = append(e.Context, rule)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// EntriesFrame converts entries back into a dictionary frame with every
+// schema column.
+func EntriesFrame(entries []Entry) dataframe.DataFrame {
+	cols := make([][]string, len(DictionaryColumns))
+	for _, e := range entries {
+		thresh := ""
+		if e.Threshold > 0 {
+			thresh = strconv.FormatFloat(float64(e.Threshold)*100, 'f', -1, 32)
+		}
+		context := make([]string, len(e.Context))
+		for i, r := range e.Context {
+			context[i] = r.String()
+		}
+		row := []string{e.Word, thresh, e.Category, strconv.Itoa(e.Severity), e.Lang, e.Country, e.Usage, strings.Join(e.Allow, "|"), strings.Join(context, "|")}
+		for i := range cols {
+			cols[i] = append(cols[i], row[i])
+		}
+	}
+	ss := make([]series.Series, len(cols))
+	for i, name := range DictionaryColumns {
+		ss[i] = series.New(cols[i], series.String, name)
+	}
+	return dataframe.New(ss...)
+}
+
+// Selector picks the part of a dictionary a filter is built from, e.g.
+// Selector{MinSeverity: 3, Lang: "ko", Country: "KR", Usage: "chat"}.
+type Selector struct {
+	MinSeverity int
+	// Categories is the set of categories to keep, all if empty.
+	Categories []string
+	// Lang, Country and Usage keep entries that are "all" or equal to the
+	// given value. Empty keeps every entry.
+	Lang, Country, Usage string
+}
+
+func (sel Selector) Match(e Entry) bool {
+	if e.Severity < sel.MinSeverity {
+		return false
+	}
+	if len(sel.Categories) > 0 {
+		found := false
+		for _, c := range sel.Categories {
+			found = found || c == e.Category
+		}
+		if !found {
+			return false
+		}
+	}
+	return scoped(sel.Lang, e.Lang) && scoped(sel.Country, e.Country) && scoped(sel.Usage, e.Usage)
+}
+
+// Select returns the entries of df chosen by sel as a new dictionary frame.
+func (sel Selector) Select(df dataframe.DataFrame) (dataframe.DataFrame, error) {
+	entries, err := ParseEntries(df)
+	if err != nil {
+		return dataframe.DataFrame{}, err
+	}
+	selected := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		if sel.Match(e) {
+			selected = append(selected, e)
+		}
+	}
+	return EntriesFrame(selected), nil
+}
+
+// Selected wraps a filter so every dictionary it is reloaded with is
+// narrowed down by sel first.
+func Selected(sel Selector, f Reloader) Reloader {
+	return &selected{sel: sel, f: f}
+}
+
+type selected struct {
+	sel Selector
+	f   Reloader
+}
+
+func (s *selected) Reload(df dataframe.DataFrame) error {
+	df, err := s.sel.Select(df)
+	if err != nil {
+		return err
+	}
+	return s.f.Reload(df)
+}
+
+func scoped(want, have string) bool {
+	return want == "" || have == "all" || strings.EqualFold(want, have)
+}
+
+func orAll(v string) string {
+	if v = cell(v); v == "" {
+		return "all"
+	}
+	return v
+}
+
+// cell trims v and treats the NaN gota reads from empty numeric cells as
+// empty.
+func cell(v string) string {
+	v = strings.TrimSpace(v)
+	if v == "NaN" {
+		return ""
+	}
+	return v
+}
@@ -0,0 +1,190 @@
+// Package eval measures NG word filters against labeled sentences.
+package eval
+
+import (
+	"ebitenprac/ngword"
+	"fmt"
+	"github.com/go-gota/gota/dataframe"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Sample is one sentence labeled true when it contains an NG word.
+type Sample struct {
+	Sentence string
+	Label    bool
+}
+
+// Detector is implemented by filters that can explain their decisions.
+// Reports of those filters include per NG word counts.
+type Detector interface {
+	Detect(sentence string) []ngword.Match
+}
+
+// ReadSamples reads a labeled CSV. The label column accepts 1/0, true/false
+// and y/n.
+func ReadSamples(r io.Reader, sentenceCol, labelCol string) ([]Sample, error) {
+	df := dataframe.ReadCSV(r, dataframe.DetectTypes(false))
+	if df.Err != nil {
+		return nil, df.Err
+	}
+	for _, c := range []string{sentenceCol, labelCol} {
+		found := false
+		for _, n := range df.Names() {
+			found = found || n == c
+		}
+		if !found {
+			return nil, fmt.Errorf("eval: no %q column", c)
+		}
+	}
+	stcs := df.Col(sentenceCol).Records()
+	labels := df.Col(labelCol).Records()
+	samples := make([]Sample, len(stcs))
+	for i := range stcs {
+		label, err := parseLabel(labels[i])
+		if err != nil {
+			return nil, fmt.Errorf("eval: line %d: %v", i+2, err)
+		}
+		samples[i] = Sample{Sentence: stcs[i], Label: label}
+	}
+	return samples, nil
+}
+
+func parseLabel(v string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "1", "true", "y", "yes":
+		return true, nil
+	case "0", "false", "n", "no":
+		return false, nil
+	}
+	return false, fmt.Errorf("bad label %q", v)
+}
+
+// WordStats counts the sentences an NG word was found in.
+type WordStats struct {
+	Word string
+	// Hits are labeled sentences, False the unlabeled ones.
+	Hits, False int
+}
+
+// Report is the confusion matrix of one filter over a set of samples.
+type Report struct {
+	Name           string
+	TP, FP, TN, FN int
+	Words          []WordStats
+}
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+func (r Report) Precision() float64 {
+	return ratio(r.TP, r.TP+r.FP)
+}
+
+func (r Report) Recall() float64 {
+	return ratio(r.TP, r.TP+r.FN)
+}
+
+func (r Report) F1() float64 {
+	p, rc := r.Precision(), r.Recall()
+	if p+rc == 0 {
+		return 0
+	}
+	return 2 * p * rc / (p + rc)
+}
+
+func (r Report) Accuracy() float64 {
+	return ratio(r.TP+r.TN, r.TP+r.FP+r.TN+r.FN)
+}
+
+// Evaluate runs f over samples. Matches suppressed by an allowlist do not
+// count as predictions.
+func Evaluate(name string, f ngword.Replacer, samples []Sample) Report {
+	r := Report{Name: name}
+	words := make(map[string]*WordStats)
+	det, isDetector := f.(Detector)
+	for _, s := range samples {
+		var predict bool
+		if isDetector {
+			seen := make(map[string]bool)
+			for _, m := range det.Detect(s.Sentence) {
+				if m.Suppressed || seen[m.Word] {
+					continue
+				}
+				seen[m.Word] = true
+				predict = true
+				ws, ok := words[m.Word]
+				if !ok {
+					ws = &WordStats{Word: m.Word}
+					words[m.Word] = ws
+				}
+				if s.Label {
+					ws.Hits++
+				} else {
+					ws.False++
+				}
+			}
+		} else {
+			_, predict = f.Replace(s.Sentence)
+		}
+
+		switch {
+		case predict && s.Label:
+			r.TP++
+		case predict && !s.Label:
+			r.FP++
+		case !predict && s.Label:
+			r.FN++
+		default:
+			r.TN++
+		}
+	}
+
+	for _, ws := range words {
+		r.Words = append(r.Words, *ws)
+	}
+	sort.Slice(r.Words, func(i, j int) bool {
+		a, b := r.Words[i], r.Words[j]
+		if a.Hits+a.False != b.Hits+b.False {
+			return a.Hits+a.False > b.Hits+b.False
+		}
+		return a.Word < b.Word
+	})
+	return r
+}
+
+// WriteSummary writes one line of metrics per report.
+func WriteSummary(w io.Writer, reports []Report) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "filter\tprecision\trecall\tf1\taccuracy\ttp\tfp\ttn\tfn")
+	for _, r := range reports {
+		fmt.Fprintf(tw, "%s\t%.4f\t%.4f\t%.4f\t%.4f\t%d\t%d\t%d\t%d\n",
+			r.Name, r.Precision(), r.Recall(), r.F1(), r.Accuracy(), r.TP, r.FP, r.TN, r.FN)
+	}
+	return tw.Flush()
+}
+
+// WriteReport writes the confusion matrix and the per word counts of r.
+func WriteReport(w io.Writer, r Report) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "== %s\n", r.Name)
+	fmt.Fprintln(tw, "\tpredicted NG\tpredicted OK")
+	fmt.Fprintf(tw, "labeled NG\t%d\t%d\n", r.TP, r.FN)
+	fmt.Fprintf(tw, "labeled OK\t%d\t%d\n", r.FP, r.TN)
+	if len(r.Words) > 0 {
+		fmt.Fprintln(tw, "\nword\thits\tfalse\tprecision")
+		for _, ws := range r.Words {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%s\n", ws.Word, ws.Hits, ws.False,
+				strconv.FormatFloat(ratio(ws.Hits, ws.Hits+ws.False), 'f', 4, 64))
+		}
+	}
+	fmt.Fprintln(tw)
+	return tw.Flush()
+}
@@ -0,0 +1,165 @@
+package eval
+
+import (
+	"ebitenprac/ngword"
+	"math"
+	"sort"
+)
+
+// Scored keeps the best similarity of every NG word found in one sample.
+type Scored struct {
+	Label bool
+	Words map[string]float64
+}
+
+// ScoreSamples runs d over samples. d should report matches well below the
+// thresholds being swept, e.g. a LocalAlignmentTrie built from
+// WithoutThresholds with a low FixedThreshold fallback.
+func ScoreSamples(d Detector, samples []Sample) []Scored {
+	scored := make([]Scored, len(samples))
+	for i, s := range samples {
+		scored[i] = Scored{Label: s.Label, Words: make(map[string]float64)}
+		for _, m := range d.Detect(s.Sentence) {
+			if m.Suppressed {
+				continue
+			}
+			// scores are float32 ratios; rounded, they compare with
+			// thresholds as the filter does
+			if v := math.Round(float64(m.Score)*1e6) / 1e6; v > scored[i].Words[m.Word] {
+				scored[i].Words[m.Word] = v
+			}
+		}
+	}
+	return scored
+}
+
+// WithoutThresholds clears the per word thresholds of entries so a filter
+// built from them uses its fallback for every word.
+func WithoutThresholds(entries []ngword.Entry) []ngword.Entry {
+	cleared := make([]ngword.Entry, len(entries))
+	copy(cleared, entries)
+	for i := range cleared {
+		cleared[i].Threshold = 0
+	}
+	return cleared
+}
+
+// Point is the performance of one threshold.
+type Point struct {
+	Threshold      float64
+	TP, FP, TN, FN int
+}
+
+func (p Point) TPR() float64 {
+	return ratio(p.TP, p.TP+p.FN)
+}
+
+func (p Point) FPR() float64 {
+	return ratio(p.FP, p.FP+p.TN)
+}
+
+func (p Point) Precision() float64 {
+	if p.TP+p.FP == 0 {
+		return 1
+	}
+	return ratio(p.TP, p.TP+p.FP)
+}
+
+// Thresholds returns from, from+step, ... up to 1.
+func Thresholds(from, step float64) []float64 {
+	ts := make([]float64, 0)
+	for i := 0; ; i++ {
+		t := from + float64(i)*step
+		if t > 1+1e-9 {
+			break
+		}
+		ts = append(ts, math.Round(t*1e6)/1e6)
+	}
+	return ts
+}
+
+// Sweep evaluates one global threshold at a time: a sample is flagged when
+// any word reaches it.
+func Sweep(scored []Scored, thresholds []float64) []Point {
+	points := make([]Point, len(thresholds))
+	for i, t := range thresholds {
+		points[i] = evaluate(scored, func(string) float64 { return t })
+		points[i].Threshold = t
+	}
+	return points
+}
+
+// EvaluateThresholds scores per word thresholds, falling back to def for the
+// words missing from them.
+func EvaluateThresholds(scored []Scored, thresholds map[string]float64, def float64) Point {
+	p := evaluate(scored, func(w string) float64 {
+		if t, ok := thresholds[w]; ok {
+			return t
+		}
+		return def
+	})
+	p.Threshold = math.NaN()
+	return p
+}
+
+func evaluate(scored []Scored, thresh func(string) float64) Point {
+	var p Point
+	for _, s := range scored {
+		predict := false
+		for w, v := range s.Words {
+			if v >= thresh(w) {
+				predict = true
+				break
+			}
+		}
+		switch {
+		case predict && s.Label:
+			p.TP++
+		case predict && !s.Label:
+			p.FP++
+		case !predict && s.Label:
+			p.FN++
+		default:
+			p.TN++
+		}
+	}
+	return p
+}
+
+// SuggestThresholds picks for every word the lowest threshold, i.e. the one
+// with the best recall, at which the word alone flags at most targetFPR of
+// the unlabeled samples. Words never seen in an unlabeled sample get floor.
+func SuggestThresholds(scored []Scored, floor, targetFPR float64) map[string]float64 {
+	negatives := 0
+	falses := make(map[string][]float64)
+	seen := make(map[string]bool)
+	for _, s := range scored {
+		if !s.Label {
+			negatives++
+		}
+		for w, v := range s.Words {
+			seen[w] = true
+			if !s.Label {
+				falses[w] = append(falses[w], v)
+			}
+		}
+	}
+
+	allowed := int(math.Floor(targetFPR * float64(negatives)))
+	suggest := make(map[string]float64)
+	for w := range seen {
+		vs := falses[w]
+		if len(vs) <= allowed {
+			suggest[w] = floor
+			continue
+		}
+		// keep the allowed highest false scores, cut just above the next one
+		sort.Sort(sort.Reverse(sort.Float64Slice(vs)))
+		t := math.Ceil(vs[allowed]*100+1e-9) / 100
+		if t < floor {
+			t = floor
+		}
+		suggest[w] = math.Min(t, 1)
+	}
+	return suggest
+}
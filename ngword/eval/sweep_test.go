@@ -0,0 +1,65 @@
+package eval
+
+import (
+	"ebitenprac/ngword"
+	"testing"
+)
+
+var sweepSentences = []string{
+	"씨발 뭐야 진짜",
+	"야 씨발 뭐야",
+	"시1발 진짜 짜증나네",
+	"병신같은 소리 하지마",
+	"ㅅㅂ 또 졌어",
+	"시발점부터 다시 생각해보자",
+	"개새끼야 그만해",
+	"존나 씨발 병신",
+	"씨이이발 병싄아",
+	"오늘 날씨 정말 좋다",
+}
+
+// TestSweptScoresMatchFilter checks that a word swept at a low threshold
+// reaches a threshold exactly when the filter at that threshold reports it.
+func TestSweptScoresMatchFilter(t *testing.T) {
+	df, err := ngword.LoadDataframeFromCSV("../../resource/ngwords.new.plain.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := ngword.ParseEntries(df)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleared := ngword.EntriesFrame(WithoutThresholds(entries))
+	filter := func(thresh float64) *ngword.LocalAlignmentTrie {
+		la, err := ngword.NewLocalAlignmentTrie(cleared)
+		if err != nil {
+			t.Fatal(err)
+		}
+		la.Fallback = ngword.FixedThreshold(float32(thresh))
+		return la
+	}
+
+	samples := make([]Sample, len(sweepSentences))
+	for i, s := range sweepSentences {
+		samples[i] = Sample{Sentence: s}
+	}
+	scored := ScoreSamples(filter(0.5), samples)
+	for _, th := range Thresholds(0.5, 0.05) {
+		la := filter(th)
+		for i, s := range sweepSentences {
+			found := make(map[string]bool)
+			for _, m := range la.Detect(s) {
+				if !m.Suppressed {
+					found[m.Word] = true
+				}
+			}
+			for _, e := range entries {
+				swept := scored[i].Words[e.Word] >= th
+				if swept != found[e.Word] {
+					t.Errorf("%q at %.2f: %s swept at %.3f, reported by the filter %v",
+						s, th, e.Word, scored[i].Words[e.Word], found[e.Word])
+				}
+			}
+		}
+	}
+}
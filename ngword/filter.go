@@ -1,24 +1,61 @@
 package ngword
 
 import (
+	"context"
+	"fmt"
 	"github.com/go-gota/gota/dataframe"
 	"github.com/go-gota/gota/series"
 	"golang.org/x/text/unicode/norm"
+	"io"
 	"sort"
 	"strings"
 	"sync"
 )
 
+// Filter masks the NG words of sentences. FilterStrings and FilterReader run
+// one over many sentences and FrameFilter over a data frame. Detectors such
+// as Verbatim only become Filters through NewMasked or a Pipeline.
 type Filter interface {
-	Do(frame dataframe.DataFrame) (dataframe.DataFrame, error)
+	Replacer
+}
+
+// FilterStrings filters stcs with f and returns their results in order.
+func FilterStrings(f Filter, stcs []string) []Result {
+	return NewStream(f).FilterStrings(stcs)
+}
+
+// FilterReader filters the lines of in with f and writes them to out.
+func FilterReader(f Filter, in io.Reader, out io.Writer) error {
+	return NewStream(f).FilterReader(in, out)
 }
 
 type LocalAlignment struct {
 	Ngwords dataframe.DataFrame
 	Result  SmithWatermanResult
+	Scorer  Scorer
+	// Gap switches to affine gap costs when set.
+	Gap *AffineGap
+
+	mu        sync.RWMutex
+	entries   []Entry
+	normalize Normalization
+}
+
+func NewLocalAlignment(df dataframe.DataFrame) (*LocalAlignment, error) {
+	la := &LocalAlignment{Scorer: DefaultScorer}
+	if err := la.Reload(df); err != nil {
+		return nil, err
+	}
+	return la, nil
 }
 
-func NewLocalAlignment(df dataframe.DataFrame) *LocalAlignment {
+// Reload swaps in the words of df. Sentences being filtered keep using the
+// old words.
+func (la *LocalAlignment) Reload(df dataframe.DataFrame) error {
+	entries, err := ParseEntries(df)
+	if err != nil {
+		return err
+	}
 	normalize := func(s series.Series) series.Series {
 		words := s.Records()
 		nor := make([]string, len(words))
@@ -28,210 +65,409 @@ func NewLocalAlignment(df dataframe.DataFrame) *LocalAlignment {
 		return series.Strings(nor)
 	}
 	df = df.Select([]string{"word"}).Capply(normalize)
-	return &LocalAlignment{Ngwords: df}
-}
-func (la *LocalAlignment) Do(df dataframe.DataFrame) (dataframe.DataFrame, error) {
-	filtered := make([]string, df.Nrow())
-	predict := make([]int, df.Nrow())
-
-	filter := func(s series.Series) series.Series {
-		stcs := s.Records()
-		for i, s := range stcs {
-			replaced, changed := la.Replace(s)
-			filtered[i] = replaced
-			if changed {
-				predict[i] = 1
-			} else {
-				predict[i] = 0
-			}
-		}
-		return s
-	}
-	df.Capply(filter)
-	df = df.Mutate(series.New(filtered, series.String, "filtered"))
-	//df = df.Mutate(series.New(predict, series.Int, "predict"))
 
-	return df, nil
+	la.mu.Lock()
+	la.Ngwords = df
+	la.entries = entries
+	la.mu.Unlock()
+	return nil
+}
+
+// SetNormalization runs n over the words and the sentences from now on.
+func (la *LocalAlignment) SetNormalization(n Normalization) {
+	la.mu.Lock()
+	la.normalize = n
+	la.mu.Unlock()
 }
 func (la *LocalAlignment) Replace(sentence string) (string, bool) {
-	changed := false
-	origin := []rune(norm.NFKD.String(sentence))
-	ngs := la.Ngwords.Maps()
-
-	result := make([]rune, len(origin))
-	copy(result, origin)
-	for _, ng := range ngs {
-		//w := []rune(norm.NFKD.String(ng["word"].(string)))
-		w := []rune(ng["word"].(string))
-		smithCh, endCh := SmithWaterman(origin, w, -0.005*float32(len(w))+0.95)
-
-	loop:
-		for {
-			select {
-			case r := <-smithCh:
-				changed = true
-				for i := r.StartPos; i <= r.EndPos; i++ {
-					result[i] = rune('*')
-				}
-			case <-endCh:
-				break loop
+	replaced, changed, _ := la.ReplaceContext(context.Background(), sentence)
+	return replaced, changed
+}
+
+// ReplaceContext is Replace giving up with the error of ctx once it is done.
+func (la *LocalAlignment) ReplaceContext(ctx context.Context, sentence string) (string, bool, error) {
+	la.mu.RLock()
+	entries, normalize := la.entries, la.normalize
+	la.mu.RUnlock()
+	text := normalize.Text(sentence)
+
+	a := getAligner(la.Scorer, la.Gap)
+	defer aligners.Put(a)
+	spans := make([]Span, 0)
+	for _, e := range entries {
+		w := []rune(normalize.String(e.Word))
+		thresh := e.Threshold
+		if thresh <= 0 {
+			thresh = LengthThreshold(len(w))
+		}
+		results, _, err := a.Align(ctx, text.Runes, w, thresh)
+		if err != nil {
+			return sentence, false, err
+		}
+		for _, r := range results {
+			if r.StartPos <= r.EndPos {
+				spans = append(spans, wordSpan(text, r))
 			}
 		}
-
 	}
-	return norm.NFC.String(string(result)), changed
+	return text.Mask(spans, '*'), len(spans) > 0, nil
 }
 
 type LocalAlignmentTrie struct {
-	trie Trie
+	trie      Trie
+	ac        *AhoCorasick
+	entries   []Entry
+	normalize Normalization
+	mu        sync.RWMutex
+	// building serializes the rebuilds, which run outside mu
+	building sync.Mutex
+	Scorer   Scorer
+	// Gap switches to affine gap costs when set.
+	Gap *AffineGap
+	// Fallback gives the threshold of words whose threshold column is
+	// empty or missing.
+	Fallback Threshold
+	// Allow suppresses matches inside benign terms, on top of the allow
+	// column of the dictionary.
+	Allow *Allowlist
+	// Prefilter takes the words held verbatim from the Aho-Corasick
+	// automaton and aligns only the rest of the sentence, reaching into the
+	// verbatim hits by the longest word for the misspelt words overlapping
+	// them. The output is the same as without it.
+	Prefilter bool
+	// Variants are other readings of the sentences searched as well.
+	Variants []Variant
 }
 
-func NewLocalAlignmentTrie(df dataframe.DataFrame) *LocalAlignmentTrie {
-	trie := NewTrie()
-	normalize := func(s series.Series) series.Series {
-		words := s.Records()
-		for _, w := range words {
-			trie.Append(norm.NFKD.String(w))
-		}
-		return s
-	}
-	df.Select([]string{"word"}).Capply(normalize)
-	return &LocalAlignmentTrie{trie: trie}
-}
-func (la *LocalAlignmentTrie) Do(df dataframe.DataFrame) (dataframe.DataFrame, error) {
-	filtered := make([]string, df.Nrow())
-	predict := make([]int, df.Nrow())
-
-	filter := func(s series.Series) series.Series {
-		token := make(chan struct{}, 8)
-		stcs := s.Records()
-		wg := &sync.WaitGroup{}
-		wg.Add(len(stcs))
-
-		for i, s := range stcs {
-			token <- struct{}{}
-			go func(idx int, str string) {
-				replaced, changed := la.Replace(str)
-				filtered[idx] = replaced
-				if changed {
-					predict[idx] = 1
-				} else {
-					predict[idx] = 0
-				}
-				<-token
-				wg.Done()
-			}(i, s)
-		}
+// NewLocalAlignmentTrie builds the trie from the dictionary df. Words with a
+// threshold use it instead of Fallback. Like every constructor taking a
+// dictionary, it fails when df breaks the schema of ParseEntries.
+func NewLocalAlignmentTrie(df dataframe.DataFrame) (*LocalAlignmentTrie, error) {
+	la := &LocalAlignmentTrie{Scorer: DefaultScorer, Fallback: LengthThreshold}
+	if err := la.Reload(df); err != nil {
+		return nil, err
+	}
+	return la, nil
+}
 
-		wg.Wait()
-		//for i, s := range stcs {
-		//	replaced, changed := la.Replace(s)
-		//	filtered[i] = replaced
-		//	if changed {
-		//		predict[i] = 1
-		//	} else {
-		//		predict[i] = 0
-		//	}
-		//}
-		return s
+// Reload builds a new trie from df and swaps it in. Sentences being filtered
+// keep using the old trie.
+func (la *LocalAlignmentTrie) Reload(df dataframe.DataFrame) error {
+	entries, err := ParseEntries(df)
+	if err != nil {
+		return err
 	}
-	df.Capply(filter)
-	df = df.Mutate(series.New(filtered, series.String, "filtered"))
-	//df = df.Mutate(series.New(predict, series.Int, "predict"))
+	la.building.Lock()
+	defer la.building.Unlock()
+	la.build(entries, la.normalize)
+	return nil
+}
 
-	return df, nil
+// SetNormalization rebuilds the trie with the words normalized by n and runs
+// n over the sentences from now on.
+func (la *LocalAlignmentTrie) SetNormalization(n Normalization) {
+	la.building.Lock()
+	defer la.building.Unlock()
+	la.build(la.entries, n)
+}
+
+// build makes a trie of entries normalized by n and swaps it in, holding
+// la.mu only for the swap. la.building must be held.
+func (la *LocalAlignmentTrie) build(entries []Entry, n Normalization) {
+	trie := NewTrie()
+	for i := range entries {
+		trie.AppendEntry(n.String(entries[i].Word), &entries[i])
+	}
+	ac := NewAhoCorasick(trie)
+
+	la.mu.Lock()
+	la.trie, la.ac = trie, ac
+	la.entries, la.normalize = entries, n
+	la.mu.Unlock()
+}
+
+// Trie returns the trie currently in use.
+func (la *LocalAlignmentTrie) Trie() Trie {
+	la.mu.RLock()
+	defer la.mu.RUnlock()
+	return la.trie
 }
 func (la *LocalAlignmentTrie) Replace(sentence string) (string, bool) {
-	changed := false
-	origin := []rune(norm.NFKD.String(sentence))
-
-	result := make([]rune, len(origin))
-	copy(result, origin)
-	smithCh := SmithWatermanTrie(origin, la.trie, 0.9)
-	for r := range smithCh {
-		changed = true
-		for i := r.StartPos; i <= r.EndPos; i++ {
-			result[i] = rune('*')
-		}
+	replaced, changed, _ := la.ReplaceContext(context.Background(), sentence)
+	return replaced, changed
+}
+
+// ReplaceContext is Replace giving up with the error of ctx once it is done.
+func (la *LocalAlignmentTrie) ReplaceContext(ctx context.Context, sentence string) (string, bool, error) {
+	return replaced(la.DecideContext(ctx, sentence))
+}
+
+// DecideContext is ReplaceContext telling the matches and the Toxicity too.
+func (la *LocalAlignmentTrie) DecideContext(ctx context.Context, sentence string) (Decision, error) {
+	text, matches, err := la.detectVariants(ctx, sentence)
+	if err != nil {
+		return Decision{Filtered: sentence}, err
 	}
-	return norm.NFC.String(string(result)), changed
+	return maskDecision(sentence, text.Mask(Spans(matches), '*'), matches), nil
 }
 
 type LocalAlignmentDebug struct {
 	Ngwords dataframe.DataFrame
 	End     []SmithWatermanEnd
+	Scorer  Scorer
+
+	mu      sync.RWMutex
+	entries []Entry
 }
 
-func NewLocalAlignmentDebug(df dataframe.DataFrame) *LocalAlignmentDebug {
-	return &LocalAlignmentDebug{
-		Ngwords: df,
-		End:     make([]SmithWatermanEnd, 0),
+func NewLocalAlignmentDebug(df dataframe.DataFrame) (*LocalAlignmentDebug, error) {
+	la := &LocalAlignmentDebug{
+		End:    make([]SmithWatermanEnd, 0),
+		Scorer: DefaultScorer,
 	}
+	if err := la.Reload(df); err != nil {
+		return nil, err
+	}
+	return la, nil
+}
+
+// Reload swaps in the words and thresholds of df.
+func (la *LocalAlignmentDebug) Reload(df dataframe.DataFrame) error {
+	entries, err := ParseEntries(df)
+	if err != nil {
+		return err
+	}
+	la.mu.Lock()
+	la.Ngwords = df
+	la.entries = entries
+	la.mu.Unlock()
+	return nil
 }
 func (la *LocalAlignmentDebug) Replace(sentence string) (string, bool) {
-	changed := false
-	origin := []rune(norm.NFKD.String(sentence))
-	ngs := la.Ngwords.Maps()
-	la.End = make([]SmithWatermanEnd, 0, len(ngs))
-
-	result := make([]rune, len(origin))
-	copy(result, origin)
-	for _, ng := range ngs {
-		w := []rune(norm.NFKD.String(ng["word"].(string)))
-		smithCh, endCh := SmithWaterman(origin, w, float32(ng["threshold"].(int))/100.0)
-
-	loop:
-		for {
-			select {
-			case r := <-smithCh:
-				changed = true
-				for i := r.StartPos; i <= r.EndPos; i++ {
-					result[i] = rune('*')
-				}
-			case e := <-endCh:
-				la.End = append(la.End, e)
-				break loop
+	text := NewText(sentence)
+	la.mu.RLock()
+	entries := la.entries
+	la.mu.RUnlock()
+	la.End = make([]SmithWatermanEnd, 0, len(entries))
+
+	a := getAligner(la.Scorer, nil)
+	defer aligners.Put(a)
+	spans := make([]Span, 0)
+	for _, e := range entries {
+		w := []rune(norm.NFKD.String(e.Word))
+		thresh := e.Threshold
+		if thresh <= 0 {
+			thresh = LengthThreshold(len(w))
+		}
+		results, end, _ := a.Align(context.Background(), text.Runes, w, thresh)
+		for _, r := range results {
+			if r.StartPos <= r.EndPos {
+				spans = append(spans, wordSpan(text, r))
 			}
 		}
+		la.End = append(la.End, end)
 	}
 
 	sort.Sort(BySimilar(la.End))
-	return norm.NFC.String(string(result)), changed
+	return text.Mask(spans, '*'), len(spans) > 0
 }
 
 type PerfectMatch struct {
 	Ngwords dataframe.DataFrame
+
+	mu sync.RWMutex
 }
 
-func NewPerfectMatch(df dataframe.DataFrame) *PerfectMatch {
-	df = df.Filter(
-		dataframe.F{"lang", "==", "all"},
-	).Filter(
-		dataframe.F{"country", "==", "all"},
-	).Filter(
-		dataframe.F{"usage", "==", "all"},
-	).Select([]string{"word"})
-	return &PerfectMatch{Ngwords: df}
-}
-func (pm *PerfectMatch) Do(df dataframe.DataFrame) (dataframe.DataFrame, error) {
-	ngs := pm.Ngwords.Maps()
-	filtered := make([]string, df.Nrow())
-	predict := make([]int, df.Nrow())
-	df = df.Mutate(series.New(filtered, series.String, "filtered"))
-	df = df.Mutate(series.New(predict, series.Int, "predict"))
-
-	filter := func(s series.Series) series.Series {
-		sentence := s.Elem(0).String()
-		for _, ng := range ngs {
-			if strings.Index(sentence, ng["word"].(string)) >= 0 {
-				replaced := strings.ReplaceAll(sentence, ng["word"].(string), "***")
-				s.Set(2, series.Strings([]string{replaced}))
-				s.Set(3, series.Ints([]int{1}))
-			}
+func NewPerfectMatch(df dataframe.DataFrame) (*PerfectMatch, error) {
+	pm := &PerfectMatch{}
+	if err := pm.Reload(df); err != nil {
+		return nil, err
+	}
+	return pm, nil
+}
+
+// Reload swaps in the words of df.
+// Only entries meant for every language, country and usage are used.
+func (pm *PerfectMatch) Reload(df dataframe.DataFrame) error {
+	entries, err := ParseEntries(df)
+	if err != nil {
+		return err
+	}
+	words := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.Lang == "all" && e.Country == "all" && e.Usage == "all" {
+			words = append(words, e.Word)
+		}
+	}
+	df = dataframe.New(series.New(words, series.String, "word"))
+
+	pm.mu.Lock()
+	pm.Ngwords = df
+	pm.mu.Unlock()
+	return nil
+}
+func (pm *PerfectMatch) Replace(sentence string) (string, bool) {
+	changed := false
+	pm.mu.RLock()
+	words := pm.Ngwords.Col("word").Records()
+	pm.mu.RUnlock()
+	for _, w := range words {
+		if strings.Index(sentence, w) >= 0 {
+			sentence = strings.ReplaceAll(sentence, w, "***")
+			changed = true
 		}
-		return s
 	}
-	df = df.Rapply(filter)
+	return sentence, changed
+}
 
-	return df, nil
+type TrieReplace struct {
+	trie Trie
+	mu   sync.RWMutex
+	Rep  rune
+}
+
+func NewTrieReplace(df dataframe.DataFrame) (*TrieReplace, error) {
+	tr := &TrieReplace{Rep: '*'}
+	if err := tr.Reload(df); err != nil {
+		return nil, err
+	}
+	return tr, nil
+}
+
+// Reload builds a new trie from df and swaps it in.
+func (tr *TrieReplace) Reload(df dataframe.DataFrame) error {
+	entries, err := ParseEntries(df)
+	if err != nil {
+		return err
+	}
+	trie := NewTrie()
+	for i := range entries {
+		trie.AppendEntry(strings.ToLower(entries[i].Word), &entries[i])
+	}
+
+	tr.mu.Lock()
+	tr.trie = trie
+	tr.mu.Unlock()
+	return nil
+}
+func (tr *TrieReplace) Replace(sentence string) (string, bool) {
+	tr.mu.RLock()
+	trie := tr.trie
+	tr.mu.RUnlock()
+	return trie.Replace(sentence, tr.Rep)
+}
+
+// AhoCorasickReplace masks the same words as TrieReplace in a single pass
+// over each sentence.
+type AhoCorasickReplace struct {
+	ac  *AhoCorasick
+	mu  sync.RWMutex
+	Rep rune
+}
+
+func NewAhoCorasickReplace(df dataframe.DataFrame) (*AhoCorasickReplace, error) {
+	ar := &AhoCorasickReplace{Rep: '*'}
+	if err := ar.Reload(df); err != nil {
+		return nil, err
+	}
+	return ar, nil
+}
+
+// Reload builds a new automaton from df and swaps it in.
+func (ar *AhoCorasickReplace) Reload(df dataframe.DataFrame) error {
+	entries, err := ParseEntries(df)
+	if err != nil {
+		return err
+	}
+	trie := NewTrie()
+	for i := range entries {
+		trie.AppendEntry(strings.ToLower(entries[i].Word), &entries[i])
+	}
+	ac := NewAhoCorasick(trie)
+
+	ar.mu.Lock()
+	ar.ac = ac
+	ar.mu.Unlock()
+	return nil
+}
+func (ar *AhoCorasickReplace) Replace(sentence string) (string, bool) {
+	ar.mu.RLock()
+	ac := ar.ac
+	ar.mu.RUnlock()
+	return ac.Replace(sentence, ar.Rep)
+}
+
+// FrameFilter runs a Filter over a column of data frames.
+type FrameFilter struct {
+	Filter Filter
+	// Input names the column of sentences, the first column when empty.
+	Input string
+	// Filtered and Predict name the columns added for the filtered
+	// sentences and for whether they changed, 1 or 0; "filtered" and
+	// "predict" when empty.
+	Filtered, Predict string
+	// Toxicity and Action, when set, name columns added for the Toxicity
+	// and the Action of the sentences.
+	Toxicity, Action string
+}
+
+func NewFrameFilter(f Filter) *FrameFilter {
+	return &FrameFilter{Filter: f, Filtered: "filtered", Predict: "predict"}
+}
+
+// Do returns df with the Filtered, Predict, Toxicity and Action columns added. Sentences that
+// failed keep their text and the first error is returned with the frame.
+func (ff *FrameFilter) Do(df dataframe.DataFrame) (dataframe.DataFrame, error) {
+	if df.Err != nil {
+		return df, df.Err
+	}
+	input := ff.Input
+	if input == "" {
+		input = df.Names()[0]
+	} else if !hasColumn(df, input) {
+		return df, fmt.Errorf("ngword: no column %q", input)
+	}
+	filteredCol, predictCol := ff.Filtered, ff.Predict
+	if filteredCol == "" {
+		filteredCol = "filtered"
+	}
+	if predictCol == "" {
+		predictCol = "predict"
+	}
+
+	results := FilterStrings(ff.Filter, df.Col(input).Records())
+	filtered := make([]string, len(results))
+	predict := make([]int, len(results))
+	toxicity := make([]float64, len(results))
+	action := make([]string, len(results))
+	var err error
+	for i, r := range results {
+		filtered[i] = r.Filtered
+		toxicity[i] = r.Toxicity
+		action[i] = r.Action.String()
+		if r.Changed {
+			predict[i] = 1
+		}
+		if r.Err != nil && err == nil {
+			err = r.Err
+		}
+	}
+	df = df.Mutate(series.New(filtered, series.String, filteredCol))
+	df = df.Mutate(series.New(predict, series.Int, predictCol))
+	if ff.Toxicity != "" {
+		df = df.Mutate(series.New(toxicity, series.Float, ff.Toxicity))
+	}
+	if ff.Action != "" {
+		df = df.Mutate(series.New(action, series.String, ff.Action))
+	}
+	return df, err
+}
+
+func hasColumn(df dataframe.DataFrame, name string) bool {
+	for _, n := range df.Names() {
+		if n == name {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,153 @@
+package ngword
+
+import (
+	"math/rand"
+	"testing"
+)
+
+const benchDict = "../resource/ngwords.new.plain.csv"
+
+var samples = []string{
+	"오늘 날씨 정말 좋다",
+	"씨발 이게 뭐야",
+	"시1발 진짜 짜증나네",
+	"병신같은 소리 하지마",
+	"this is a perfectly fine sentence",
+	"ㅅㅂ 또 졌어",
+	"시발점부터 다시 생각해보자",
+	"개새끼야 그만해",
+	"존나 씨발 병신",
+}
+
+func loadEntries(tb testing.TB) []Entry {
+	tb.Helper()
+	df, err := LoadDataframeFromCSV(benchDict)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	entries, err := ParseEntries(df)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return entries
+}
+
+// randomSentences draws n sentences from the runes of the dictionary words,
+// spaces and a few marks, so that words, near misses and words split by
+// separators all turn up.
+func randomSentences(entries []Entry, n, maxLen int, seed int64) []string {
+	alphabet := []rune(" .!a1")
+	for _, e := range entries {
+		alphabet = append(alphabet, []rune(e.Word)...)
+	}
+	rnd := rand.New(rand.NewSource(seed))
+	stcs := make([]string, n)
+	for i := range stcs {
+		rs := make([]rune, 1+rnd.Intn(maxLen))
+		for j := range rs {
+			rs[j] = alphabet[rnd.Intn(len(alphabet))]
+		}
+		stcs[i] = string(rs)
+	}
+	return stcs
+}
+
+// long joins sentences, over and over, into one input of n runes.
+func long(sentences []string, n int) string {
+	rs := make([]rune, 0, n+1)
+	for i := 0; len(rs) < n; i++ {
+		rs = append(rs, []rune(sentences[i%len(sentences)])...)
+		rs = append(rs, ' ')
+	}
+	return string(rs[:n])
+}
+
+func TestExactReplacersAgree(t *testing.T) {
+	df := EntriesFrame(loadEntries(t))
+	tr, err := NewTrieReplace(df)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ar, err := NewAhoCorasickReplace(df)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stcs := append(randomSentences(loadEntries(t), 3000, 12, 1), samples...)
+	for _, s := range stcs {
+		want, wantChanged := tr.Replace(s)
+		got, changed := ar.Replace(s)
+		if got != want || changed != wantChanged {
+			t.Errorf("%q: aho gives %q %v, trie gives %q %v", s, got, changed, want, wantChanged)
+		}
+	}
+}
+
+func TestPrefilterKeepsOutput(t *testing.T) {
+	df := EntriesFrame(loadEntries(t))
+	la, err := NewLocalAlignmentTrie(df)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pf, err := NewLocalAlignmentTrie(df)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pf.Prefilter = true
+	stcs := append(randomSentences(loadEntries(t), 500, 12, 2), samples...)
+	for _, s := range stcs {
+		want, wantChanged := la.Replace(s)
+		if got, changed := pf.Replace(s); got != want || changed != wantChanged {
+			t.Errorf("%q: %q %v with the prefilter, %q %v without", s, got, changed, want, wantChanged)
+		}
+	}
+}
+
+type namedReplacer struct {
+	name string
+	r    Replacer
+}
+
+func benchReplacers(b *testing.B) []namedReplacer {
+	df := EntriesFrame(loadEntries(b))
+	pm, _ := NewPerfectMatch(df)
+	tr, _ := NewTrieReplace(df)
+	ar, _ := NewAhoCorasickReplace(df)
+	trie, _ := NewLocalAlignmentTrie(df)
+	prefiltered, _ := NewLocalAlignmentTrie(df)
+	prefiltered.Prefilter = true
+	affine, _ := NewLocalAlignmentTrie(df)
+	affine.Gap = &DefaultAffineGap
+	return []namedReplacer{
+		{"perfect", pm},
+		{"replace", tr},
+		{"aho", ar},
+		{"trie", trie},
+		{"trie+prefilter", prefiltered},
+		{"trie+affine", affine},
+	}
+}
+
+// BenchmarkReplace filters one sample sentence per op.
+func BenchmarkReplace(b *testing.B) {
+	for _, f := range benchReplacers(b) {
+		b.Run(f.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				f.r.Replace(samples[i%len(samples)])
+			}
+		})
+	}
+}
+
+// BenchmarkReplaceLong filters an input of 10k runes per op.
+func BenchmarkReplaceLong(b *testing.B) {
+	input := long(samples, 10000)
+	for _, f := range benchReplacers(b) {
+		b.Run(f.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				f.r.Replace(input)
+			}
+		})
+	}
+}
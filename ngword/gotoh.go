@@ -0,0 +1,104 @@
+package ngword
+
+// GapCost is an affine gap penalty: a gap of n runes scores
+// Open + (n-1)*Extend.
+type GapCost struct {
+	Open   int `json:"open"`
+	Extend int `json:"extend"`
+}
+
+// AffineGap holds separate gap costs for runes inserted into the sentence,
+// such as filler typed between syllables, and for runes of the NG word that
+// are missing from the sentence.
+type AffineGap struct {
+	Insert GapCost `json:"insert"`
+	Delete GapCost `json:"delete"`
+}
+
+// DefaultAffineGap makes runs of filler cheap compared to dropped jamo.
+var DefaultAffineGap = AffineGap{
+	Insert: GapCost{Open: -2, Extend: -1},
+	Delete: GapCost{Open: -3, Extend: -2},
+}
+
+const minScore = -987654321
+
+// deadGotoh stands in for cells pruned by Aligner.AlignTrie.
+var deadGotoh = gotohNode{H: minScore, E: minScore, F: minScore}
+
+// gotohNode holds the three Gotoh matrices for one cell. H is the best
+// alignment ending at the cell, E the best ending in an insertion and F the
+// best ending in a deletion.
+type gotohNode struct {
+	H, E, F   int
+	Next      int
+	EExtended bool
+	FExtended bool
+}
+
+// gotohCell fills c, the cell of sentence rune x and word rune w, from its
+// diagonal, upper and left neighbours.
+func gotohCell(sc Scorer, gap AffineGap, diag, up, left *gotohNode, c *gotohNode, x, w rune) {
+	open, ext := up.H+gap.Delete.Open, up.F+gap.Delete.Extend
+	c.F, c.FExtended = open, false
+	if ext > open {
+		c.F, c.FExtended = ext, true
+	}
+
+	open, ext = left.H+gap.Insert.Open, left.E+gap.Insert.Extend
+	c.E, c.EExtended = open, false
+	if ext > open {
+		c.E, c.EExtended = ext, true
+	}
+
+	ijscore := diag.H + sc.Score(x, w)
+	if ijscore >= c.F && ijscore >= c.E {
+		c.H, c.Next = ijscore, NEXT_IJ
+	} else if c.F >= c.E {
+		c.H, c.Next = c.F, NEXT_I
+	} else {
+		c.H, c.Next = c.E, NEXT_J
+	}
+}
+
+// gotohHistory traces the alignment ending at (lenWord, endPos) back to the
+// sentence position it starts from.
+func gotohHistory(m [][]gotohNode, lenWord, endPos int) int {
+	t, s := lenWord, endPos
+	state := NEXT_IJ
+	for {
+		n := m[t][s]
+		switch state {
+		case NEXT_I:
+			if !n.FExtended {
+				state = NEXT_IJ
+			}
+			t--
+		case NEXT_J:
+			if !n.EExtended {
+				state = NEXT_IJ
+			}
+			s--
+		default:
+			switch n.Next {
+			case NEXT_END:
+				return s
+			case NEXT_IJ:
+				t, s = t-1, s-1
+			default:
+				state = n.Next
+			}
+		}
+	}
+}
+
+// SmithWatermanAffine is SmithWaterman with affine gap costs. sc only scores
+// substitutions; gaps are scored by gap alone.
+func SmithWatermanAffine(sc Scorer, gap AffineGap, sentence, word []rune, thresh float32) (<-chan SmithWatermanResult, <-chan SmithWatermanEnd) {
+	return streamAlign(NewAligner(sc, &gap), sentence, word, thresh)
+}
+
+// SmithWatermanTrieAffine is SmithWatermanTrie with affine gap costs.
+func SmithWatermanTrieAffine(sc Scorer, gap AffineGap, sentence []rune, words Trie, fallback Threshold) <-chan SmithWatermanResult {
+	return streamAlignTrie(NewAligner(sc, &gap), sentence, words, fallback)
+}
@@ -0,0 +1,152 @@
+package ngword
+
+import (
+	"golang.org/x/text/unicode/norm"
+)
+
+// Dubeolsik reads runs of Latin letters as keys typed on the standard Korean
+// two-set keyboard with the input method left in English mode, so "tlqkf"
+// becomes 시발. Every jamo keeps the span of the keys that typed it. Used as a
+// Normalizer it drops the Latin reading; as a Variant the sentence is also
+// searched as typed.
+type Dubeolsik struct{}
+
+// DubeolsikVariant searches sentences as typed and as read by Dubeolsik.
+var DubeolsikVariant = Variant{Name: "dubeolsik", Normalizer: Dubeolsik{}}
+
+// dubeolsik maps keys to initial consonants and medial vowels.
+var dubeolsik = map[rune]rune{
+	'q': 0x1107, 'w': 0x110C, 'e': 0x1103, 'r': 0x1100, 't': 0x1109,
+	'y': 0x116D, 'u': 0x1167, 'i': 0x1163, 'o': 0x1162, 'p': 0x1166,
+	'a': 0x1106, 's': 0x1102, 'd': 0x110B, 'f': 0x1105, 'g': 0x1112,
+	'h': 0x1169, 'j': 0x1165, 'k': 0x1161, 'l': 0x1175,
+	'z': 0x110F, 'x': 0x1110, 'c': 0x110E, 'v': 0x1111,
+	'b': 0x1172, 'n': 0x116E, 'm': 0x1173,
+	'Q': 0x1108, 'W': 0x110D, 'E': 0x1104, 'R': 0x1101, 'T': 0x110A,
+	'O': 0x1164, 'P': 0x1168,
+}
+
+// jongseong maps initial consonants to the same consonant as a final.
+var jongseong = map[rune]rune{
+	0x1100: 0x11A8, 0x1101: 0x11A9, 0x1102: 0x11AB, 0x1103: 0x11AE,
+	0x1105: 0x11AF, 0x1106: 0x11B7, 0x1107: 0x11B8, 0x1109: 0x11BA,
+	0x110A: 0x11BB, 0x110B: 0x11BC, 0x110C: 0x11BD, 0x110E: 0x11BE,
+	0x110F: 0x11BF, 0x1110: 0x11C0, 0x1111: 0x11C1, 0x1112: 0x11C2,
+}
+
+// compound joins a vowel or final with the jamo typed after it.
+var compound = map[[2]rune]rune{
+	{0x1169, 0x1161}: 0x116A, {0x1169, 0x1162}: 0x116B, {0x1169, 0x1175}: 0x116C,
+	{0x116E, 0x1165}: 0x116F, {0x116E, 0x1166}: 0x1170, {0x116E, 0x1175}: 0x1171,
+	{0x1173, 0x1175}: 0x1174,
+	{0x11A8, 0x1109}: 0x11AA, {0x11AB, 0x110C}: 0x11AC, {0x11AB, 0x1112}: 0x11AD,
+	{0x11AF, 0x1100}: 0x11B0, {0x11AF, 0x1106}: 0x11B1, {0x11AF, 0x1107}: 0x11B2,
+	{0x11AF, 0x1109}: 0x11B3, {0x11AF, 0x1110}: 0x11B4, {0x11AF, 0x1111}: 0x11B5,
+	{0x11AF, 0x1112}: 0x11B6, {0x11B8, 0x1109}: 0x11B9,
+}
+
+func isVowel(r rune) bool {
+	return r >= 0x1161 && r <= 0x1175
+}
+
+func dubeolsikKey(r rune) (rune, bool) {
+	if j, ok := dubeolsik[r]; ok {
+		return j, true
+	}
+	if r >= 'A' && r <= 'Z' {
+		j, ok := dubeolsik[r-'A'+'a']
+		return j, ok
+	}
+	return 0, false
+}
+
+func (Dubeolsik) Normalize(t *Text) {
+	runes := make([]rune, 0, len(t.Runes))
+	spans := make([]Span, 0, len(t.Spans))
+	for i := 0; i < len(t.Runes); {
+		if _, ok := dubeolsikKey(t.Runes[i]); !ok {
+			runes = append(runes, t.Runes[i])
+			spans = append(spans, t.Spans[i])
+			i++
+			continue
+		}
+		j := i
+		keys := make([]rune, 0)
+		for ; j < len(t.Runes); j++ {
+			k, ok := dubeolsikKey(t.Runes[j])
+			if !ok {
+				break
+			}
+			keys = append(keys, k)
+		}
+		jamo, from, to := compose(keys)
+		for k := range jamo {
+			runes = append(runes, jamo[k])
+			spans = append(spans, Span{t.Spans[i+from[k]].Start, t.Spans[i+to[k]].End})
+		}
+		i = j
+	}
+	t.Runes, t.Spans = runes, spans
+}
+
+// compose assembles keys into the conjoining jamo of syllables. A consonant
+// before a vowel starts a syllable, any other one closes the syllable before
+// it if it can. The jamo k was typed by keys from[k]..to[k].
+func compose(keys []rune) (jamo []rune, from, to []int) {
+	// state of the syllable being built: 0 none, 1 initial, 2 vowel, 3 final
+	state := 0
+	push := func(r rune, k int) {
+		jamo, from, to = append(jamo, r), append(from, k), append(to, k)
+	}
+	join := func(r rune, k int) bool {
+		last := len(jamo) - 1
+		c, ok := compound[[2]rune{jamo[last], r}]
+		if ok {
+			jamo[last], to[last] = c, k
+		}
+		return ok
+	}
+	for k, r := range keys {
+		if isVowel(r) {
+			switch {
+			case state == 1:
+				push(r, k)
+				state = 2
+			case state == 2 && join(r, k):
+			default:
+				push(r, k)
+				state = 2
+			}
+			continue
+		}
+		nextVowel := k+1 < len(keys) && isVowel(keys[k+1])
+		if !nextVowel {
+			if f, ok := jongseong[r]; ok && state == 2 {
+				push(f, k)
+				state = 3
+				continue
+			}
+			if state == 3 && join(r, k) {
+				continue
+			}
+		}
+		push(r, k)
+		state = 1
+	}
+	return jamo, from, to
+}
+
+// Variant is a second reading of every sentence, such as Hangul typed on an
+// English keyboard layout. Words are looked for in both and the ones found
+// only in the reading are reported with its Name.
+type Variant struct {
+	Name       string
+	Normalizer Normalizer
+}
+
+// Reading returns s as the variant reads it, composed back into syllables.
+func (v Variant) Reading(s string) string {
+	t := NewText(s)
+	v.Normalizer.Normalize(t)
+	return norm.NFC.String(string(t.Runes))
+}
@@ -0,0 +1,62 @@
+package ngword
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDubeolsikReading(t *testing.T) {
+	for _, c := range []struct {
+		typed, want string
+	}{
+		{"tlqkf", "시발"},
+		{"dkssud", "안녕"},
+		{"Tlqkf", "씨발"},
+		{"dkssudgktpdy", "안녕하세요"},
+		// double finals, kept unless a vowel takes their second consonant
+		{"ekfr", "닭"},
+		{"qkqt", "밦"},
+		{"dksw", "앉"},
+		{"dlfrdj", "읽어"},
+		{"ekfrdl", "닭이"},
+		// compound vowels
+		{"dhk", "와"},
+		{"rhkd", "광"},
+		{"dml", "의"},
+		{"dnpq", "웹"},
+		// a consonant before a vowel leaves the syllable before it
+		{"rkrk", "가가"},
+		{"gksrmf", "한글"},
+		{"tlqkf 뭐야", "시발 뭐야"},
+	} {
+		if got := DubeolsikVariant.Reading(c.typed); got != c.want {
+			t.Errorf("%q: got %q, want %q", c.typed, got, c.want)
+		}
+	}
+}
+
+func TestComposeKeys(t *testing.T) {
+	keys := func(s string) []rune {
+		ks := make([]rune, 0, len(s))
+		for _, r := range s {
+			k, _ := dubeolsikKey(r)
+			ks = append(ks, k)
+		}
+		return ks
+	}
+	for _, c := range []struct {
+		typed    string
+		jamo     []rune
+		from, to []int
+	}{
+		{"ekfr", []rune{0x1103, 0x1161, 0x11B0}, []int{0, 1, 2}, []int{0, 1, 3}},
+		{"dhk", []rune{0x110B, 0x116A}, []int{0, 1}, []int{0, 2}},
+		{"rkrk", []rune{0x1100, 0x1161, 0x1100, 0x1161}, []int{0, 1, 2, 3}, []int{0, 1, 2, 3}},
+		{"rr", []rune{0x1100, 0x1100}, []int{0, 1}, []int{0, 1}},
+	} {
+		jamo, from, to := compose(keys(c.typed))
+		if !reflect.DeepEqual(jamo, c.jamo) || !reflect.DeepEqual(from, c.from) || !reflect.DeepEqual(to, c.to) {
+			t.Errorf("%q: got %U %v %v, want %U %v %v", c.typed, jamo, from, to, c.jamo, c.from, c.to)
+		}
+	}
+}
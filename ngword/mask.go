@@ -0,0 +1,242 @@
+package ngword
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/text/unicode/norm"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaskMode is how Masking rewrites an NG word.
+type MaskMode int
+
+const (
+	// MaskFull masks every character, one Rune per jamo as filters always
+	// did.
+	MaskFull MaskMode = iota
+	// MaskKeepFirst keeps the first character and masks the others with one
+	// Rune each, 씨발 becoming 씨*.
+	MaskKeepFirst
+	// MaskToken replaces the word with Token whatever its length.
+	MaskToken
+	// MaskLabel replaces the word with its category, such as [욕설].
+	MaskLabel
+	// MaskRemove drops the word and the space left doubled by it.
+	MaskRemove
+)
+
+var maskModeNames = [...]string{"full", "keep-first", "token", "label", "remove"}
+
+func (m MaskMode) String() string {
+	if m < 0 || int(m) >= len(maskModeNames) {
+		return fmt.Sprintf("MaskMode(%d)", int(m))
+	}
+	return maskModeNames[m]
+}
+
+func (m MaskMode) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *MaskMode) UnmarshalText(b []byte) error {
+	for i, name := range maskModeNames {
+		if string(b) == name {
+			*m = MaskMode(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("ngword: unknown mask mode %q", string(b))
+}
+
+// Masking rewrites the NG words of a sentence on its original offsets. The
+// zero Masking is the full mask with '*'.
+type Masking struct {
+	Mode MaskMode `json:"mode"`
+	// Rune masks characters in MaskFull and MaskKeepFirst, "*" if empty.
+	Rune string `json:"rune,omitempty"`
+	// Token replaces words in MaskToken, and in MaskLabel the ones without
+	// a category, "***" if empty.
+	Token string `json:"token,omitempty"`
+}
+
+func (m Masking) rune() rune {
+	if r, _ := utf8.DecodeRuneInString(m.Rune); m.Rune != "" {
+		return r
+	}
+	return '*'
+}
+
+func (m Masking) token() string {
+	if m.Token == "" {
+		return "***"
+	}
+	return m.Token
+}
+
+// region is a stretch of the sentence covered by matches, labelled with the
+// category of its most severe match.
+type region struct {
+	Span
+	category string
+	severity int
+}
+
+// regions merges the spans of the matches that are not suppressed, with the
+// spaces and punctuation alignments take in at their ends trimmed.
+func regions(s string, matches []Match) []region {
+	rs := make([]region, 0, len(matches))
+	for _, m := range matches {
+		if m.Suppressed {
+			continue
+		}
+		sp := trimSpanFunc(s, Span{m.Start, m.End}, separator)
+		if sp.Start < sp.End {
+			rs = append(rs, region{sp, m.Category, m.Severity})
+		}
+	}
+	sort.Slice(rs, func(i, j int) bool {
+		return rs[i].Start < rs[j].Start
+	})
+	merged := make([]region, 0, len(rs))
+	for _, r := range rs {
+		if n := len(merged); n > 0 && r.Start < merged[n-1].End {
+			last := &merged[n-1]
+			if r.End > last.End {
+				last.End = r.End
+			}
+			if r.severity > last.severity {
+				last.category, last.severity = r.category, r.severity
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+func separator(r rune) bool {
+	return unicode.IsSpace(r) || unicode.IsPunct(r)
+}
+
+// Apply rewrites the matches of sentence that are not suppressed.
+func (m Masking) Apply(sentence string, matches []Match) string {
+	rs := regions(sentence, matches)
+	if len(rs) == 0 {
+		return sentence
+	}
+	if m.Mode == MaskFull {
+		spans := make([]Span, len(rs))
+		for i, r := range rs {
+			spans[i] = r.Span
+		}
+		return NewText(sentence).Mask(spans, m.rune())
+	}
+
+	var b strings.Builder
+	b.Grow(len(sentence))
+	last, removed := 0, false
+	for _, r := range rs {
+		b.WriteString(junction(&b, sentence[last:r.Start], removed))
+		removed = false
+		word := sentence[r.Start:r.End]
+		switch m.Mode {
+		case MaskKeepFirst:
+			n := norm.NFC.NextBoundaryInString(word, true)
+			if n <= 0 {
+				_, n = utf8.DecodeRuneInString(word)
+			}
+			b.WriteString(word[:n])
+			for rest := word[n:]; rest != ""; {
+				k := norm.NFC.NextBoundaryInString(rest, true)
+				if k <= 0 {
+					_, k = utf8.DecodeRuneInString(rest)
+				}
+				b.WriteRune(m.rune())
+				rest = rest[k:]
+			}
+		case MaskToken:
+			b.WriteString(m.token())
+		case MaskLabel:
+			if r.category == "" {
+				b.WriteString(m.token())
+			} else {
+				b.WriteString("[" + r.category + "]")
+			}
+		case MaskRemove:
+			removed = true
+		}
+		last = r.End
+	}
+	tail := junction(&b, sentence[last:], removed)
+	if removed && tail == "" {
+		return strings.TrimRightFunc(b.String(), unicode.IsSpace)
+	}
+	b.WriteString(tail)
+	return b.String()
+}
+
+// junction returns the text between two words, without its leading spaces
+// when the word before it was removed and nothing but space precedes it.
+func junction(b *strings.Builder, text string, removed bool) string {
+	if !removed {
+		return text
+	}
+	out := b.String()
+	if r, _ := utf8.DecodeLastRuneInString(out); out == "" || unicode.IsSpace(r) {
+		return strings.TrimLeftFunc(text, unicode.IsSpace)
+	}
+	return text
+}
+
+// ReplaceWith rewrites the NG words d finds in sentence with m.
+func ReplaceWith(d Detector, sentence string, m Masking) (string, bool) {
+	replaced, changed, _ := ReplaceWithContext(context.Background(), d, sentence, m)
+	return replaced, changed
+}
+
+// ReplaceWithContext is ReplaceWith giving up with the error of ctx once it
+// is done.
+func ReplaceWithContext(ctx context.Context, d Detector, sentence string, m Masking) (string, bool, error) {
+	matches, err := d.DetectContext(ctx, sentence)
+	if err != nil {
+		return sentence, false, err
+	}
+	return m.Apply(sentence, matches), len(Spans(matches)) > 0, nil
+}
+
+// Masked is the Filter rewriting what its Detector finds with its Masking
+// instead of the '*' of the Detector's own Replace.
+type Masked struct {
+	Detector Detector
+	Masking  Masking
+}
+
+func NewMasked(d Detector, m Masking) *Masked {
+	return &Masked{Detector: d, Masking: m}
+}
+
+func (m *Masked) Replace(sentence string) (string, bool) {
+	return ReplaceWith(m.Detector, sentence, m.Masking)
+}
+
+// ReplaceContext is Replace giving up with the error of ctx once it is done.
+func (m *Masked) ReplaceContext(ctx context.Context, sentence string) (string, bool, error) {
+	return ReplaceWithContext(ctx, m.Detector, sentence, m.Masking)
+}
+
+// DecideContext is ReplaceContext telling the matches and the Toxicity too.
+func (m *Masked) DecideContext(ctx context.Context, sentence string) (Decision, error) {
+	matches, err := m.Detector.DetectContext(ctx, sentence)
+	if err != nil {
+		return Decision{Filtered: sentence}, err
+	}
+	return maskDecision(sentence, m.Masking.Apply(sentence, matches), matches), nil
+}
+
+// DetectContext is the DetectContext of the Detector.
+func (m *Masked) DetectContext(ctx context.Context, sentence string) ([]Match, error) {
+	return m.Detector.DetectContext(ctx, sentence)
+}
@@ -0,0 +1,41 @@
+package ngword
+
+import (
+	"strings"
+	"testing"
+)
+
+// span returns the match of word in s, widened by wider bytes on each side
+// the way alignments take in their neighbours.
+func span(s, word string, before, after int) Match {
+	i := strings.Index(s, word)
+	return Match{Word: word, Start: i - before, End: i + len(word) + after, Severity: 3}
+}
+
+func TestMaskingTrimsSeparators(t *testing.T) {
+	for _, c := range []struct {
+		mode     MaskMode
+		sentence string
+		match    Match
+		want     string
+	}{
+		{MaskFull, "야 씨발 뭐야", span("야 씨발 뭐야", "씨발", 1, 1), "야 ***** 뭐야"},
+		{MaskFull, "씨발! 뭐야", span("씨발! 뭐야", "씨발", 0, 2), "*****! 뭐야"},
+		{MaskFull, "야 씨발", span("야 씨발", "씨발", 1, 0), "야 *****"},
+		{MaskKeepFirst, "야 씨발 뭐야", span("야 씨발 뭐야", "씨발", 1, 1), "야 씨* 뭐야"},
+		{MaskToken, "야 씨발 뭐야", span("야 씨발 뭐야", "씨발", 1, 1), "야 *** 뭐야"},
+	} {
+		got := Masking{Mode: c.mode}.Apply(c.sentence, []Match{c.match})
+		if got != c.want {
+			t.Errorf("%v %q: got %q, want %q", c.mode, c.sentence, got, c.want)
+		}
+	}
+}
+
+func TestMaskingSkipsSuppressed(t *testing.T) {
+	m := span("야 씨발 뭐야", "씨발", 1, 1)
+	m.Suppressed = true
+	if got := (Masking{}).Apply("야 씨발 뭐야", []Match{m}); got != "야 씨발 뭐야" {
+		t.Errorf("got %q", got)
+	}
+}
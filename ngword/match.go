@@ -0,0 +1,253 @@
+package ngword
+
+import (
+	"context"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Match describes one NG word found in a sentence. Offsets are half-open
+// ranges into the sentence exactly as it was passed to Detect.
+type Match struct {
+	Word      string  `json:"word"`
+	Start     int     `json:"start"`
+	End       int     `json:"end"`
+	RuneStart int     `json:"rune_start"`
+	RuneEnd   int     `json:"rune_end"`
+	Score     float32 `json:"score"`
+	Threshold float32 `json:"threshold"`
+	Category  string  `json:"category,omitempty"`
+	Severity  int     `json:"severity"`
+	// Suppressed matches lie inside an allowlisted term or break a context
+	// rule of their entry, AllowedBy, and are reported but not masked.
+	Suppressed bool   `json:"suppressed,omitempty"`
+	AllowedBy  string `json:"allowed_by,omitempty"`
+	// Variant names the reading the word was found in, such as "dubeolsik",
+	// and Reading is the matched part as read; both are empty for hits in
+	// the sentence as written.
+	Variant string `json:"variant,omitempty"`
+	Reading string `json:"reading,omitempty"`
+}
+
+// newMatch maps a result over t.Runes back onto t.Origin, up to the end of
+// the word and not the separators the alignment skips after it.
+func newMatch(t *Text, r SmithWatermanResult) Match {
+	sp := wordSpan(t, r)
+	m := Match{
+		Word:      r.MatchWord,
+		Start:     sp.Start,
+		End:       sp.End,
+		RuneStart: t.RuneOffset(sp.Start),
+		RuneEnd:   t.RuneOffset(sp.End),
+		Score:     r.SimilarScore,
+		Threshold: r.Threshold,
+		Severity:  DefaultSeverity,
+	}
+	if r.Entry != nil {
+		m.Category = r.Entry.Category
+		m.Severity = r.Entry.Severity
+	}
+	return m
+}
+
+// allow suppresses m if an exception of its entry or a term of a covers it,
+// or if it breaks a context rule of its entry.
+func allow(m *Match, t *Text, e *Entry, a *Allowlist) {
+	sp := Span{m.Start, m.End}
+	if e != nil {
+		if term, ok := covering(e.Allow, t.Origin, sp); ok {
+			m.Suppressed, m.AllowedBy = true, term
+			return
+		}
+		if rule, ok := checkContext(e.Context, t.Origin, sp); !ok {
+			m.Suppressed, m.AllowedBy = true, rule.String()
+			return
+		}
+	}
+	if term, ok := a.Covering(t.Origin, sp); ok {
+		m.Suppressed, m.AllowedBy = true, term
+	}
+}
+
+// wordSpan is the original byte range of the runes r aligns to its word.
+func wordSpan(t *Text, r SmithWatermanResult) Span {
+	if r.WordEnd < r.StartPos {
+		return t.Span(r.StartPos, r.EndPos)
+	}
+	return t.Span(r.StartPos, r.WordEnd)
+}
+
+// Detect returns every NG word hit in sentence without masking it, including
+// the ones suppressed by the allowlist.
+func (la *LocalAlignmentTrie) Detect(sentence string) []Match {
+	_, matches, _ := la.detectVariants(context.Background(), sentence)
+	return matches
+}
+
+// DetectContext is Detect giving up with the error of ctx once it is done.
+func (la *LocalAlignmentTrie) DetectContext(ctx context.Context, sentence string) ([]Match, error) {
+	_, matches, err := la.detectVariants(ctx, sentence)
+	return matches, err
+}
+
+// detectVariants looks for words in sentence and in its Variants. It also
+// returns the sentence as normalized, the Text the matches mask.
+func (la *LocalAlignmentTrie) detectVariants(ctx context.Context, sentence string) (*Text, []Match, error) {
+	la.mu.RLock()
+	nz := la.normalize
+	la.mu.RUnlock()
+	t := nz.Text(sentence)
+	matches, err := la.detect(ctx, t)
+	if err != nil {
+		return t, nil, err
+	}
+	for _, v := range la.Variants {
+		vt := append(Normalization{v.Normalizer}, nz...).Text(sentence)
+		if string(vt.Runes) == string(t.Runes) {
+			continue
+		}
+		found, err := la.detect(ctx, vt)
+		if err != nil {
+			return t, nil, err
+		}
+		for _, m := range found {
+			// words read the same either way are reported once
+			if !hasMatch(matches, m) {
+				m.Variant, m.Reading = v.Name, v.Reading(sentence[m.Start:m.End])
+				matches = append(matches, m)
+			}
+		}
+	}
+	return t, matches, nil
+}
+
+func hasMatch(matches []Match, m Match) bool {
+	for _, o := range matches {
+		if o.Word == m.Word && o.Start < m.End && m.Start < o.End {
+			return true
+		}
+	}
+	return false
+}
+
+func (la *LocalAlignmentTrie) detect(ctx context.Context, t *Text) ([]Match, error) {
+	la.mu.RLock()
+	trie, ac := la.trie, la.ac
+	la.mu.RUnlock()
+	a := getAligner(la.Scorer, la.Gap)
+	defer aligners.Put(a)
+	depth := a.flatten(trie, la.Fallback)
+
+	matches := make([]Match, 0)
+	// gaps are the stretches of t.Runes left to align
+	gaps := []Span{{0, len(t.Runes)}}
+	type hit struct {
+		Span
+		word string
+	}
+	verbatim := make(map[hit]bool)
+	if la.Prefilter {
+		matches = la.exact(t, ac)
+		gaps = unmatched(t, matches, depth)
+		for _, m := range matches {
+			verbatim[hit{Span{m.Start, m.End}, m.Word}] = true
+		}
+	}
+	for _, g := range gaps {
+		results, err := a.alignFlat(ctx, t.Runes[g.Start:g.End], depth)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range results {
+			if r.StartPos > r.EndPos {
+				continue
+			}
+			r.StartPos, r.EndPos, r.WordEnd = r.StartPos+g.Start, r.EndPos+g.Start, r.WordEnd+g.Start
+			m := newMatch(t, r)
+			if verbatim[hit{Span{m.Start, m.End}, m.Word}] {
+				continue
+			}
+			allow(&m, t, r.Entry, la.Allow)
+			matches = append(matches, m)
+		}
+	}
+	return matches, nil
+}
+
+// unmatched returns the runs of t.Runes outside the matches that are not
+// suppressed, widened by pad runes on both sides.
+func unmatched(t *Text, matches []Match, pad int) []Span {
+	spans := MergeSpans(Spans(matches))
+	gaps := make([]Span, 0, len(spans)+1)
+	add := func(start, end int) {
+		start, end = start-pad, end+pad
+		if start < 0 {
+			start = 0
+		}
+		if end > len(t.Runes) {
+			end = len(t.Runes)
+		}
+		if n := len(gaps); n > 0 && start <= gaps[n-1].End {
+			gaps[n-1].End = end
+			return
+		}
+		gaps = append(gaps, Span{start, end})
+	}
+	start, k := 0, 0
+	for i, sp := range t.Spans {
+		for k < len(spans) && spans[k].End <= sp.Start {
+			k++
+		}
+		if k < len(spans) && spans[k].Start <= sp.Start && sp.End <= spans[k].End {
+			if start < i {
+				add(start, i)
+			}
+			start = i + 1
+		}
+	}
+	if start < len(t.Runes) {
+		add(start, len(t.Runes))
+	}
+	return gaps
+}
+
+// exact returns the verbatim hits of ac in t as perfect matches.
+func (la *LocalAlignmentTrie) exact(t *Text, ac *AhoCorasick) []Match {
+	return exactMatches(t, ac, la.Fallback, la.Allow)
+}
+
+func exactMatches(t *Text, ac *AhoCorasick, fallback Threshold, a *Allowlist) []Match {
+	matches := make([]Match, 0)
+	for _, em := range ac.Find(t.Runes) {
+		r := SmithWatermanResult{
+			MatchWord:    norm.NFKC.String(em.Word),
+			SimilarScore: 1,
+			Threshold:    fallback(em.End - em.Start + 1),
+			StartPos:     em.Start,
+			EndPos:       em.End,
+			WordEnd:      em.End,
+			Entry:        em.Entry,
+		}
+		if em.Entry != nil {
+			r.MatchWord = em.Entry.Word
+			if em.Entry.Threshold > 0 {
+				r.Threshold = em.Entry.Threshold
+			}
+		}
+		m := newMatch(t, r)
+		allow(&m, t, em.Entry, a)
+		matches = append(matches, m)
+	}
+	return matches
+}
+
+// Spans returns the original byte ranges covered by the matches that are
+// not suppressed.
+func Spans(matches []Match) []Span {
+	spans := make([]Span, 0, len(matches))
+	for _, m := range matches {
+		if !m.Suppressed {
+			spans = append(spans, Span{m.Start, m.End})
+		}
+	}
+	return spans
+}
@@ -6,10 +6,22 @@ import (
 )
 
 func ReadDataframeFromCSV(fname string) dataframe.DataFrame {
-	fp, err := os.Open(fname)
+	df, err := LoadDataframeFromCSV(fname)
 	if err != nil {
 		panic(err)
 	}
+	return df
+}
+
+// LoadDataframeFromCSV is ReadDataframeFromCSV returning open and parse
+// errors instead of panicking. Every column is read as strings, so the
+// cells keep the text of the file.
+func LoadDataframeFromCSV(fname string) (dataframe.DataFrame, error) {
+	fp, err := os.Open(fname)
+	if err != nil {
+		return dataframe.DataFrame{}, err
+	}
 	defer fp.Close()
-	return dataframe.ReadCSV(fp)
+	df := dataframe.ReadCSV(fp, dataframe.DetectTypes(false))
+	return df, df.Err
 }
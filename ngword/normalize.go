@@ -0,0 +1,262 @@
+package ngword
+
+import (
+	"encoding/json"
+	"fmt"
+	"golang.org/x/text/unicode/norm"
+	"os"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Normalizer is a stage run over a Text after NFKD. It may drop, replace or
+// add runes, but every rune it leaves must carry the span of the original
+// runes it stands for, so matches still map back onto the input.
+type Normalizer interface {
+	Normalize(t *Text)
+}
+
+// Normalization chains Normalizers. The same Normalization has to be used for
+// the dictionary and for the sentences; a nil Normalization is plain NFKD.
+type Normalization []Normalizer
+
+// Text decomposes s with NewText and runs every stage over it.
+func (n Normalization) Text(s string) *Text {
+	t := NewText(s)
+	for _, st := range n {
+		st.Normalize(t)
+	}
+	return t
+}
+
+// String normalizes a dictionary word the way Text normalizes sentences.
+func (n Normalization) String(s string) string {
+	if len(n) == 0 {
+		return norm.NFKD.String(s)
+	}
+	return string(n.Text(s).Runes)
+}
+
+// ZeroWidth drops invisible runes used to split words apart: zero-width
+// spaces and joiners, soft hyphens, word joiners and the Hangul fillers.
+type ZeroWidth struct{}
+
+func isZeroWidth(r rune) bool {
+	switch r {
+	case 0x00AD, 0x034F, 0x115F, 0x1160, 0x180E, 0x200B, 0x200C, 0x200D, 0x2060, 0x3164, 0xFEFF, 0xFFA0:
+		return true
+	}
+	return false
+}
+
+func (ZeroWidth) Normalize(t *Text) {
+	runes, spans := t.Runes[:0], t.Spans[:0]
+	for i, r := range t.Runes {
+		if !isZeroWidth(r) {
+			runes = append(runes, r)
+			spans = append(spans, t.Spans[i])
+		}
+	}
+	t.Runes, t.Spans = runes, spans
+}
+
+// Confusables replaces look-alike runes, such as 1 for ㅣ or a Cyrillic а for
+// a Latin a, with the runes they imitate. Look-alikes of Hangul are replaced
+// only in runs that are already Hangul, so the l of hello stays a Latin l.
+type Confusables map[rune][]rune
+
+// DefaultConfusables covers the look-alikes seen most in chat.
+var DefaultConfusables = NewConfusables(map[string]string{
+	"1": "ㅣ", "|": "ㅣ", "!": "ㅣ", "l": "ㅣ", "丨": "ㅣ",
+	"人": "ㅅ", "口": "ㅁ", "己": "ㄹ", "卜": "ㅏ", "一": "ㅡ",
+	"а": "a", "е": "e", "о": "o", "р": "p", "с": "c", "у": "y", "х": "x", "і": "i", "ѕ": "s",
+	"α": "a", "ο": "o", "ι": "i", "ν": "v",
+})
+
+// NewConfusables builds Confusables from single rune keys, dropping the
+// others. Keys and replacements are taken in NFKD form.
+func NewConfusables(m map[string]string) Confusables {
+	c := make(Confusables, len(m))
+	for k, v := range m {
+		k = norm.NFKD.String(k)
+		if utf8.RuneCountInString(k) != 1 {
+			continue
+		}
+		r, _ := utf8.DecodeRuneInString(k)
+		c[r] = []rune(norm.NFKD.String(v))
+	}
+	return c
+}
+
+func (c Confusables) Normalize(t *Text) {
+	runes := make([]rune, 0, len(t.Runes))
+	spans := make([]Span, 0, len(t.Spans))
+	for i, r := range t.Runes {
+		rep, ok := c[r]
+		if !ok || unicode.Is(unicode.Hangul, rep[0]) && !c.inHangul(t.Runes, i) {
+			rep = []rune{r}
+		}
+		for _, r := range rep {
+			runes = append(runes, r)
+			spans = append(spans, t.Spans[i])
+		}
+	}
+	t.Runes, t.Spans = runes, spans
+}
+
+// inHangul tells whether the first rune on either side of runes[i], past
+// other look-alikes and short of a space, is Hangul.
+func (c Confusables) inHangul(runes []rune, i int) bool {
+	for _, step := range [...]int{-1, 1} {
+		for j := i + step; j >= 0 && j < len(runes) && !unicode.IsSpace(runes[j]); j += step {
+			if _, ok := c[runes[j]]; !ok {
+				if unicode.Is(unicode.Hangul, runes[j]) {
+					return true
+				}
+				break
+			}
+		}
+	}
+	return false
+}
+
+// FoldJamo spells final consonants, including the clusters that compatibility
+// jamo such as ㄳ decompose to, with initial consonants. Jamo typed one by one
+// and the batchim of a syllable then compare equal.
+type FoldJamo struct{}
+
+// finals maps the final consonants U+11A8..U+11C2 to initial consonants.
+var finals = [...][]rune{
+	{0x1100}, {0x1101}, {0x1100, 0x1109}, {0x1102}, {0x1102, 0x110C}, {0x1102, 0x1112}, {0x1103},
+	{0x1105}, {0x1105, 0x1100}, {0x1105, 0x1106}, {0x1105, 0x1107}, {0x1105, 0x1109}, {0x1105, 0x1110},
+	{0x1105, 0x1111}, {0x1105, 0x1112}, {0x1106}, {0x1107}, {0x1107, 0x1109}, {0x1109}, {0x110A},
+	{0x110B}, {0x110C}, {0x110E}, {0x110F}, {0x1110}, {0x1111}, {0x1112},
+}
+
+func (FoldJamo) Normalize(t *Text) {
+	runes := make([]rune, 0, len(t.Runes))
+	spans := make([]Span, 0, len(t.Spans))
+	for i, r := range t.Runes {
+		rep := []rune{r}
+		if r >= 0x11A8 && r <= 0x11C2 {
+			rep = finals[r-0x11A8]
+		}
+		for _, r := range rep {
+			runes = append(runes, r)
+			spans = append(spans, t.Spans[i])
+		}
+	}
+	t.Runes, t.Spans = runes, spans
+}
+
+// CollapseRepeats keeps at most Max copies of a character repeated in a row.
+// The kept copy takes over the span of the dropped ones, so masking it masks
+// the whole run.
+type CollapseRepeats struct {
+	Max int
+}
+
+func (c CollapseRepeats) Normalize(t *Text) {
+	max := c.Max
+	if max < 1 {
+		max = 1
+	}
+	runes := make([]rune, 0, len(t.Runes))
+	spans := make([]Span, 0, len(t.Spans))
+	// prev is the last unit kept, a run of runes sharing one span
+	prevStart, count := -1, 0
+	for i := 0; i < len(t.Runes); {
+		j := i + 1
+		for j < len(t.Runes) && t.Spans[j] == t.Spans[i] {
+			j++
+		}
+		unit := t.Runes[i:j]
+		if prevStart >= 0 && string(runes[prevStart:]) == string(unit) {
+			count++
+		} else {
+			count = 1
+		}
+		if count > max {
+			for k := prevStart; k < len(spans); k++ {
+				spans[k].End = t.Spans[i].End
+			}
+		} else {
+			prevStart = len(runes)
+			runes = append(runes, unit...)
+			for k := i; k < j; k++ {
+				spans = append(spans, t.Spans[i])
+			}
+		}
+		i = j
+	}
+	t.Runes, t.Spans = runes, spans
+}
+
+// NormalizeConfig selects the stages of a Normalization, in order, from
+// "zero-width", "confusables", "dubeolsik", "jamo" and "repeats".
+// Confusables replaces DefaultConfusables when given, and MaxRepeat defaults
+// to 2.
+type NormalizeConfig struct {
+	Stages      []string          `json:"stages"`
+	Confusables map[string]string `json:"confusables,omitempty"`
+	MaxRepeat   int               `json:"max_repeat,omitempty"`
+}
+
+// DefaultNormalization is the pipeline of resource/normalize.default.json.
+var DefaultNormalization = Normalization{
+	ZeroWidth{},
+	DefaultConfusables,
+	FoldJamo{},
+	CollapseRepeats{Max: 2},
+}
+
+func (c NormalizeConfig) Normalization() (Normalization, error) {
+	n := make(Normalization, 0, len(c.Stages))
+	for _, name := range c.Stages {
+		switch name {
+		case "zero-width":
+			n = append(n, ZeroWidth{})
+		case "confusables":
+			if c.Confusables != nil {
+				n = append(n, NewConfusables(c.Confusables))
+			} else {
+				n = append(n, DefaultConfusables)
+			}
+		case "dubeolsik":
+			n = append(n, Dubeolsik{})
+		case "jamo":
+			n = append(n, FoldJamo{})
+		case "repeats":
+			max := c.MaxRepeat
+			if max == 0 {
+				max = 2
+			}
+			n = append(n, CollapseRepeats{Max: max})
+		default:
+			return nil, fmt.Errorf("ngword: unknown normalization stage %q", name)
+		}
+	}
+	return n, nil
+}
+
+// LoadNormalizeConfig reads a NormalizeConfig from the JSON file fname.
+func LoadNormalizeConfig(fname string) (NormalizeConfig, error) {
+	var c NormalizeConfig
+	fp, err := os.Open(fname)
+	if err != nil {
+		return c, err
+	}
+	defer fp.Close()
+
+	err = json.NewDecoder(fp).Decode(&c)
+	return c, err
+}
+
+// LoadNormalization reads a NormalizeConfig from the JSON file fname.
+func LoadNormalization(fname string) (Normalization, error) {
+	c, err := LoadNormalizeConfig(fname)
+	if err != nil {
+		return nil, err
+	}
+	return c.Normalization()
+}
@@ -0,0 +1,64 @@
+package ngword
+
+import (
+	"golang.org/x/text/unicode/norm"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeStages(t *testing.T) {
+	for _, c := range []struct {
+		stage Normalizer
+		in    string
+		want  string
+	}{
+		{ZeroWidth{}, "시\u200b발", "시발"},
+		{ZeroWidth{}, "씨\u2060발\ufeff", "씨발"},
+		{DefaultConfusables, "ㅅ1발", "ㅅㅣ발"},
+		{DefaultConfusables, "시|!발", "시ㅣㅣ발"},
+		{DefaultConfusables, "ssіbal", "ssibal"},
+		// look-alikes of Hangul stay as they are outside Hangul
+		{DefaultConfusables, "hello 1 l", "hello 1 l"},
+		{DefaultConfusables, "ssibal1", "ssibal1"},
+		{FoldJamo{}, "닭", "다ㄹㄱ"},
+		{FoldJamo{}, "ㄳ", "ㄱㅅ"},
+		{CollapseRepeats{Max: 2}, "ㅋㅋㅋㅋㅋ", "ㅋㅋ"},
+		{CollapseRepeats{Max: 1}, "씨이이발", "씨이발"},
+	} {
+		got := string(Normalization{c.stage}.Text(c.in).Runes)
+		if want := norm.NFKD.String(c.want); got != want {
+			t.Errorf("%T %q: got %q, want %q", c.stage, c.in, got, want)
+		}
+	}
+}
+
+func TestNormalizeKeepsSpans(t *testing.T) {
+	// every rune left must point into the original, so the dropped and
+	// collapsed characters are masked along with their neighbours
+	for _, c := range []struct {
+		n      Normalization
+		in     string
+		spans  []Span
+		masked string
+	}{
+		{Normalization{ZeroWidth{}}, "시\u200b발", []Span{{0, 3}, {0, 3}, {6, 9}, {6, 9}, {6, 9}}, "******"},
+		{Normalization{CollapseRepeats{Max: 1}}, "아아아", []Span{{0, 9}, {0, 9}}, "******"},
+		{Normalization{CollapseRepeats{Max: 2}}, "ㅋㅋㅋㅋ", []Span{{0, 3}, {3, 12}}, "****"},
+		{Normalization{CollapseRepeats{Max: 2}}, "ㅋㅋㅋ 뭐", []Span{{0, 3}, {3, 9}, {9, 10}, {10, 13}, {10, 13}}, "*** 뭐"},
+		{Normalization{DefaultConfusables, FoldJamo{}}, "ㅅ1", []Span{{0, 3}, {3, 4}}, "**"},
+	} {
+		text := c.n.Text(c.in)
+		if !reflect.DeepEqual(text.Spans, c.spans) {
+			t.Errorf("%q: got spans %v, want %v", c.in, text.Spans, c.spans)
+			continue
+		}
+		// mask the runes standing for the first word
+		end := 0
+		for end+1 < len(text.Runes) && text.Runes[end+1] != ' ' {
+			end++
+		}
+		if got := text.Mask([]Span{text.Span(0, end)}, '*'); got != c.masked {
+			t.Errorf("%q: masked to %q, want %q", c.in, got, c.masked)
+		}
+	}
+}
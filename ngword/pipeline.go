@@ -0,0 +1,368 @@
+package ngword
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-gota/gota/dataframe"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Verbatim detects dictionary words written as they are, after
+// normalization, with Aho-Corasick. It is far cheaper than the alignment but
+// misses every misspelling.
+type Verbatim struct {
+	// Fallback gives the reported threshold of words without one.
+	Fallback Threshold
+	Allow    *Allowlist
+
+	mu        sync.RWMutex
+	ac        *AhoCorasick
+	entries   []Entry
+	normalize Normalization
+	// building serializes the rebuilds, which run outside mu
+	building sync.Mutex
+}
+
+func NewVerbatim(df dataframe.DataFrame) (*Verbatim, error) {
+	v := &Verbatim{Fallback: LengthThreshold}
+	if err := v.Reload(df); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Reload swaps in the words of df.
+func (v *Verbatim) Reload(df dataframe.DataFrame) error {
+	entries, err := ParseEntries(df)
+	if err != nil {
+		return err
+	}
+	v.building.Lock()
+	defer v.building.Unlock()
+	v.build(entries, v.normalize)
+	return nil
+}
+
+// SetNormalization rebuilds the automaton with the words normalized by n and
+// runs n over the sentences from now on.
+func (v *Verbatim) SetNormalization(n Normalization) {
+	v.building.Lock()
+	defer v.building.Unlock()
+	v.build(v.entries, n)
+}
+
+// build makes an automaton of entries normalized by n and swaps it in,
+// holding v.mu only for the swap. v.building must be held.
+func (v *Verbatim) build(entries []Entry, n Normalization) {
+	trie := NewTrie()
+	for i := range entries {
+		trie.AppendEntry(n.String(entries[i].Word), &entries[i])
+	}
+	ac := NewAhoCorasick(trie)
+
+	v.mu.Lock()
+	v.ac = ac
+	v.entries, v.normalize = entries, n
+	v.mu.Unlock()
+}
+
+func (v *Verbatim) Detect(sentence string) []Match {
+	matches, _ := v.DetectContext(context.Background(), sentence)
+	return matches
+}
+
+// DetectContext is Detect. It is quick enough to never check ctx.
+func (v *Verbatim) DetectContext(ctx context.Context, sentence string) ([]Match, error) {
+	v.mu.RLock()
+	ac, n := v.ac, v.normalize
+	v.mu.RUnlock()
+	return exactMatches(n.Text(sentence), ac, v.Fallback, v.Allow), nil
+}
+
+// Action is what a Pipeline does with a sentence, from the mildest to the
+// strictest.
+type Action int
+
+const (
+	// Pass leaves the sentence as it is.
+	Pass Action = iota
+	// Mask replaces the NG words.
+	Mask
+	// Flag masks the NG words and holds the sentence for review.
+	Flag
+	// Block rejects the whole sentence.
+	Block
+)
+
+var actionNames = [...]string{"pass", "mask", "flag", "block"}
+
+func (a Action) String() string {
+	if a < 0 || int(a) >= len(actionNames) {
+		return fmt.Sprintf("Action(%d)", int(a))
+	}
+	return actionNames[a]
+}
+
+func (a Action) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *Action) UnmarshalText(b []byte) error {
+	for i, name := range actionNames {
+		if string(b) == name {
+			*a = Action(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("ngword: unknown action %q", string(b))
+}
+
+// Policy decides what happens to a sentence holding NG words. Action applies
+// to every such sentence; a word of at least FlagSeverity or BlockSeverity
+// escalates it to Flag or Block. Zero severities never escalate, and with
+// Pass only the escalated sentences change.
+type Policy struct {
+	Action        Action `json:"action"`
+	FlagSeverity  int    `json:"flag_severity,omitempty"`
+	BlockSeverity int    `json:"block_severity,omitempty"`
+	// Masking rewrites the NG words of masked and flagged sentences.
+	Masking Masking `json:"masking"`
+	// BlockText replaces a blocked sentence.
+	BlockText string `json:"block_text,omitempty"`
+}
+
+// DefaultPolicy masks every NG word.
+var DefaultPolicy = Policy{Action: Mask}
+
+// Decision is the outcome of a Pipeline for one sentence.
+type Decision struct {
+	Action   Action  `json:"action"`
+	Filtered string  `json:"filtered"`
+	Matches  []Match `json:"matches"`
+	// Spans are the merged ranges of the matches that are not suppressed.
+	Spans []Span `json:"spans"`
+	// Toxicity rates the matches, see Toxicity.
+	Toxicity float64 `json:"toxicity"`
+}
+
+// Decide applies p to the matches found in sentence.
+func (p Policy) Decide(sentence string, matches []Match) Decision {
+	d := Decision{
+		Filtered: sentence,
+		Matches:  matches,
+		Spans:    MergeSpans(Spans(matches)),
+		Toxicity: Toxicity(matches),
+	}
+	if len(d.Spans) == 0 {
+		return d
+	}
+	d.Action = p.Action
+	for _, m := range matches {
+		if m.Suppressed {
+			continue
+		}
+		if p.BlockSeverity > 0 && m.Severity >= p.BlockSeverity {
+			d.Action = Block
+		} else if p.FlagSeverity > 0 && m.Severity >= p.FlagSeverity && d.Action < Flag {
+			d.Action = Flag
+		}
+	}
+	switch d.Action {
+	case Pass:
+		return d
+	case Block:
+		d.Filtered = p.BlockText
+		return d
+	}
+	d.Filtered = p.Masking.Apply(sentence, matches)
+	return d
+}
+
+// MergeSpans sorts spans and joins the ones that overlap or touch.
+func MergeSpans(spans []Span) []Span {
+	if len(spans) == 0 {
+		return spans
+	}
+	sorted := append([]Span(nil), spans...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Start < sorted[j].Start
+	})
+	merged := sorted[:1]
+	for _, sp := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if sp.Start <= last.End {
+			if sp.End > last.End {
+				last.End = sp.End
+			}
+			continue
+		}
+		merged = append(merged, sp)
+	}
+	return merged
+}
+
+// Pipeline runs its Detectors over every sentence, exact matchers before
+// fuzzy ones, and hands what they found to its Policy.
+type Pipeline struct {
+	Combined
+	Policy Policy
+}
+
+func NewPipeline(policy Policy, detectors ...Detector) *Pipeline {
+	return &Pipeline{Combined: Combined{Detectors: detectors}, Policy: policy}
+}
+
+func (p *Pipeline) Replace(sentence string) (string, bool) {
+	replaced, changed, _ := p.ReplaceContext(context.Background(), sentence)
+	return replaced, changed
+}
+
+// ReplaceContext returns the Filtered sentence of the Decision.
+func (p *Pipeline) ReplaceContext(ctx context.Context, sentence string) (string, bool, error) {
+	return replaced(p.DecideContext(ctx, sentence))
+}
+
+func (p *Pipeline) Decide(sentence string) Decision {
+	d, _ := p.DecideContext(context.Background(), sentence)
+	return d
+}
+
+// DecideContext is Decide giving up with the error of ctx once it is done.
+func (p *Pipeline) DecideContext(ctx context.Context, sentence string) (Decision, error) {
+	matches, err := p.DetectContext(ctx, sentence)
+	if err != nil {
+		return Decision{Filtered: sentence}, err
+	}
+	return p.Policy.Decide(sentence, matches), nil
+}
+
+// FuzzyConfig sets up one fuzzy matcher of a Pipeline: "trie", the
+// LocalAlignmentTrie, or "romanized".
+type FuzzyConfig struct {
+	Type string `json:"type"`
+	// Scores is a scoring profile file, the built-in profile if empty.
+	Scores string `json:"scores,omitempty"`
+	// Threshold replaces LengthThreshold for words without a threshold.
+	Threshold float32 `json:"threshold,omitempty"`
+	Prefilter bool    `json:"prefilter,omitempty"`
+}
+
+// PipelineConfig is the JSON form of a Pipeline. The allowlists apply to
+// every matcher, the normalization to the exact and trie matchers and
+// Variants, such as "dubeolsik", to the trie matchers.
+type PipelineConfig struct {
+	Normalize *NormalizeConfig `json:"normalize,omitempty"`
+	Variants  []string         `json:"variants,omitempty"`
+	// Exact adds a Verbatim matcher in front of the fuzzy ones.
+	Exact  bool          `json:"exact,omitempty"`
+	Fuzzy  []FuzzyConfig `json:"fuzzy"`
+	Allow  []string      `json:"allow,omitempty"`
+	Policy Policy        `json:"policy"`
+}
+
+// DefaultPipelineConfig masks what LocalAlignmentTrie finds, as the filters
+// did before pipelines.
+var DefaultPipelineConfig = PipelineConfig{
+	Fuzzy:  []FuzzyConfig{{Type: "trie"}},
+	Policy: DefaultPolicy,
+}
+
+// LoadPipelineConfig reads a PipelineConfig from the JSON file fname. The
+// policy fields it leaves out keep the values of DefaultPolicy.
+func LoadPipelineConfig(fname string) (PipelineConfig, error) {
+	c := PipelineConfig{Policy: DefaultPolicy}
+	fp, err := os.Open(fname)
+	if err != nil {
+		return c, err
+	}
+	defer fp.Close()
+
+	dec := json.NewDecoder(fp)
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&c)
+	return c, err
+}
+
+// Build creates the Pipeline described by c with the dictionary df.
+func (c PipelineConfig) Build(df dataframe.DataFrame) (*Pipeline, error) {
+	var nz Normalization
+	if c.Normalize != nil {
+		var err error
+		if nz, err = c.Normalize.Normalization(); err != nil {
+			return nil, err
+		}
+	}
+	var allow *Allowlist
+	if len(c.Allow) > 0 {
+		allow = NewAllowlist()
+		for _, fname := range c.Allow {
+			a, err := LoadAllowlist(fname)
+			if err != nil {
+				return nil, err
+			}
+			allow.Terms = append(allow.Terms, a.Terms...)
+		}
+	}
+	variants := make([]Variant, 0, len(c.Variants))
+	for _, name := range c.Variants {
+		switch name {
+		case DubeolsikVariant.Name:
+			variants = append(variants, DubeolsikVariant)
+		default:
+			return nil, fmt.Errorf("ngword: unknown variant %q", name)
+		}
+	}
+
+	// the matchers get nz before their first Reload so that each builds its
+	// words once
+	p := NewPipeline(c.Policy)
+	if c.Exact {
+		v := &Verbatim{Fallback: LengthThreshold, Allow: allow, normalize: nz}
+		if err := v.Reload(df); err != nil {
+			return nil, err
+		}
+		p.Detectors = append(p.Detectors, v)
+	}
+	for _, f := range c.Fuzzy {
+		sc := DefaultScorer
+		if f.Scores != "" {
+			var err error
+			if sc, err = LoadScoreProfile(f.Scores); err != nil {
+				return nil, err
+			}
+		}
+		fallback := LengthThreshold
+		if f.Threshold > 0 {
+			fallback = FixedThreshold(f.Threshold)
+		}
+		switch f.Type {
+		case "trie":
+			la := &LocalAlignmentTrie{
+				Scorer:    sc,
+				Gap:       sc.Affine,
+				Fallback:  fallback,
+				Allow:     allow,
+				Prefilter: f.Prefilter,
+				Variants:  variants,
+				normalize: nz,
+			}
+			if err := la.Reload(df); err != nil {
+				return nil, err
+			}
+			p.Detectors = append(p.Detectors, la)
+		case "romanized":
+			ro, err := NewRomanized(df)
+			if err != nil {
+				return nil, err
+			}
+			ro.Scorer, ro.Fallback, ro.Allow = sc, fallback, allow
+			p.Detectors = append(p.Detectors, ro)
+		default:
+			return nil, fmt.Errorf("ngword: unknown fuzzy matcher %q", f.Type)
+		}
+	}
+	return p, nil
+}
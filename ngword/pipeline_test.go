@@ -0,0 +1,67 @@
+package ngword
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPolicyEscalates(t *testing.T) {
+	const sentence = "야 씨발 뭐야"
+	word := func(severity int, suppressed bool) Match {
+		m := span(sentence, "씨발", 0, 0)
+		m.Severity, m.Suppressed = severity, suppressed
+		return m
+	}
+	p := Policy{Action: Mask, FlagSeverity: 3, BlockSeverity: 5, BlockText: "[blocked]"}
+	for _, c := range []struct {
+		policy   Policy
+		matches  []Match
+		action   Action
+		filtered string
+	}{
+		{p, nil, Pass, sentence},
+		{p, []Match{word(1, false)}, Mask, "야 ***** 뭐야"},
+		{p, []Match{word(3, false)}, Flag, "야 ***** 뭐야"},
+		{p, []Match{word(3, false), word(5, false)}, Block, "[blocked]"},
+		{p, []Match{word(5, false), word(3, false)}, Block, "[blocked]"},
+		// suppressed words neither count nor escalate
+		{p, []Match{word(5, true)}, Pass, sentence},
+		{p, []Match{word(1, false), word(5, true)}, Mask, "야 ***** 뭐야"},
+		// zero severities never escalate
+		{Policy{Action: Mask}, []Match{word(5, false)}, Mask, "야 ***** 뭐야"},
+		// with Pass only the escalated sentences change
+		{Policy{Action: Pass, FlagSeverity: 3}, []Match{word(1, false)}, Pass, sentence},
+		{Policy{Action: Pass, FlagSeverity: 3}, []Match{word(3, false)}, Flag, "야 ***** 뭐야"},
+		// escalation never lowers the action
+		{Policy{Action: Block, FlagSeverity: 3}, []Match{word(3, false)}, Block, ""},
+	} {
+		d := c.policy.Decide(sentence, c.matches)
+		if d.Action != c.action || d.Filtered != c.filtered {
+			t.Errorf("%+v %v: got %v %q, want %v %q", c.policy, c.matches, d.Action, d.Filtered, c.action, c.filtered)
+		}
+	}
+}
+
+func TestMergeSpans(t *testing.T) {
+	for _, c := range []struct {
+		spans, want []Span
+	}{
+		{nil, nil},
+		{[]Span{{0, 3}}, []Span{{0, 3}}},
+		{[]Span{{5, 8}, {0, 3}}, []Span{{0, 3}, {5, 8}}},
+		// overlapping and touching spans join
+		{[]Span{{0, 4}, {2, 6}}, []Span{{0, 6}}},
+		{[]Span{{0, 3}, {3, 6}}, []Span{{0, 6}}},
+		// a span inside another leaves it as it is
+		{[]Span{{0, 9}, {2, 4}, {10, 12}}, []Span{{0, 9}, {10, 12}}},
+		{[]Span{{6, 9}, {0, 2}, {1, 7}}, []Span{{0, 9}}},
+	} {
+		in := append([]Span(nil), c.spans...)
+		if got := MergeSpans(c.spans); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("MergeSpans(%v) = %v, want %v", in, got, c.want)
+		}
+		if !reflect.DeepEqual(c.spans, in) {
+			t.Errorf("MergeSpans changed its input %v to %v", in, c.spans)
+		}
+	}
+}
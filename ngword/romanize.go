@@ -0,0 +1,220 @@
+package ngword
+
+import (
+	"context"
+	"github.com/go-gota/gota/dataframe"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Revised Romanization of the conjoining jamo, by initial, medial and final.
+var (
+	romanInitials = [...]string{"g", "kk", "n", "d", "tt", "r", "m", "b", "pp", "s", "ss", "", "j", "jj", "ch", "k", "t", "p", "h"}
+	romanMedials  = [...]string{"a", "ae", "ya", "yae", "eo", "e", "yeo", "ye", "o", "wa", "wae", "oe", "yo", "u", "wo", "we", "wi", "yu", "eu", "ui", "i"}
+	romanFinals   = [...]string{"k", "k", "k", "n", "n", "n", "t", "l", "k", "m", "l", "l", "l", "p", "l", "m", "p", "p", "t", "t", "ng", "t", "t", "k", "t", "p", "t"}
+)
+
+// Romanize spells the Hangul of s in the Revised Romanization, jamo by jamo
+// and without the sound changes between syllables. Other runes are kept,
+// Latin letters in lower case.
+func Romanize(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFKD.String(s) {
+		switch {
+		case r >= 0x1100 && r <= 0x1112:
+			b.WriteString(romanInitials[r-0x1100])
+		case r >= 0x1161 && r <= 0x1175:
+			b.WriteString(romanMedials[r-0x1161])
+		case r >= 0x11A8 && r <= 0x11C2:
+			b.WriteString(romanFinals[r-0x11A8])
+		default:
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+// PhoneticKey reduces Latin letters to a key shared by the usual ways of
+// spelling the same Korean sounds: "ssibal", "shibal" and "sibbal" all become
+// "sipal". Voiced and unvoiced consonants, r and l, and the digraphs of
+// vowels are merged, and a letter repeated in a row is kept once.
+type PhoneticKey struct{}
+
+var phoneticDigraphs = map[string]rune{
+	"sh": 's', "ch": 'j', "kh": 'k', "th": 't', "ph": 'p',
+	"eo": 'o', "eu": 'u', "oo": 'u', "ae": 'e', "ee": 'i',
+}
+
+var phoneticLetters = map[rune]rune{
+	'g': 'k', 'c': 'k', 'q': 'k', 'd': 't', 'b': 'p', 'f': 'p', 'v': 'p', 'r': 'l', 'z': 'j',
+}
+
+func (PhoneticKey) Normalize(t *Text) {
+	runes := make([]rune, 0, len(t.Runes))
+	spans := make([]Span, 0, len(t.Spans))
+	for i := 0; i < len(t.Runes); {
+		r, sp := unicode.ToLower(t.Runes[i]), t.Spans[i]
+		if r > unicode.MaxASCII || !unicode.IsLetter(r) {
+			runes = append(runes, r)
+			spans = append(spans, sp)
+			i++
+			continue
+		}
+		if i+1 < len(t.Runes) {
+			if k, ok := phoneticDigraphs[string([]rune{r, unicode.ToLower(t.Runes[i+1])})]; ok {
+				r, sp = k, t.Span(i, i+1)
+				i++
+			}
+		}
+		if k, ok := phoneticLetters[r]; ok {
+			r = k
+		}
+		i++
+		if last := len(runes) - 1; last >= 0 && runes[last] == r {
+			spans[last].End = sp.End
+			continue
+		}
+		runes = append(runes, r)
+		spans = append(spans, sp)
+	}
+	t.Runes, t.Spans = runes, spans
+}
+
+// phoneticKey is the key of a romanized string.
+var phoneticKey = Normalization{PhoneticKey{}}
+
+// Romanized finds Korean NG words written in Latin letters, such as "ssibal"
+// or "jonna". Dictionary words holding Hangul are romanized, both sides are
+// reduced to their PhoneticKey and the keys are aligned like the jamo of
+// LocalAlignmentTrie.
+type Romanized struct {
+	Scorer Scorer
+	// Fallback gives the threshold of words whose threshold column is
+	// empty or missing.
+	Fallback Threshold
+	// MinKey drops words whose key is shorter, as they would match inside
+	// too many English words. It takes effect on the next Reload.
+	MinKey int
+	// WholeWord only reports matches that begin and end a word, so that
+	// "jonna" is found but "Jonathan" is not.
+	WholeWord bool
+	Allow     *Allowlist
+
+	mu   sync.RWMutex
+	trie Trie
+}
+
+func NewRomanized(df dataframe.DataFrame) (*Romanized, error) {
+	r := &Romanized{
+		Scorer:    DefaultScorer,
+		Fallback:  LengthThreshold,
+		MinKey:    4,
+		WholeWord: true,
+	}
+	if err := r.Reload(df); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload builds a new trie of keys from df and swaps it in.
+func (ro *Romanized) Reload(df dataframe.DataFrame) error {
+	entries, err := ParseEntries(df)
+	if err != nil {
+		return err
+	}
+	trie := NewTrie()
+	// words spelled alike, such as 씨발 and 씨이발, share a key; the first
+	// one keeps it
+	seen := make(map[string]bool)
+	for i := range entries {
+		if !hasHangul(entries[i].Word) {
+			continue
+		}
+		key := phoneticKey.String(Romanize(entries[i].Word))
+		if utf8.RuneCountInString(key) < ro.MinKey || seen[key] {
+			continue
+		}
+		seen[key] = true
+		trie.AppendEntry(key, &entries[i])
+	}
+
+	ro.mu.Lock()
+	ro.trie = trie
+	ro.mu.Unlock()
+	return nil
+}
+
+func hasHangul(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Hangul, r) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ro *Romanized) Replace(sentence string) (string, bool) {
+	replaced, changed, _ := ro.ReplaceContext(context.Background(), sentence)
+	return replaced, changed
+}
+
+// ReplaceContext is Replace giving up with the error of ctx once it is done.
+func (ro *Romanized) ReplaceContext(ctx context.Context, sentence string) (string, bool, error) {
+	return replaced(ro.DecideContext(ctx, sentence))
+}
+
+// DecideContext is ReplaceContext telling the matches and the Toxicity too.
+func (ro *Romanized) DecideContext(ctx context.Context, sentence string) (Decision, error) {
+	matches, err := ro.DetectContext(ctx, sentence)
+	if err != nil {
+		return Decision{Filtered: sentence}, err
+	}
+	return maskDecision(sentence, NewText(sentence).Mask(Spans(matches), '*'), matches), nil
+}
+
+func (ro *Romanized) Detect(sentence string) []Match {
+	matches, _ := ro.DetectContext(context.Background(), sentence)
+	return matches
+}
+
+// DetectContext is Detect giving up with the error of ctx once it is done.
+// Its matches are reported with the Variant "romanized".
+func (ro *Romanized) DetectContext(ctx context.Context, sentence string) ([]Match, error) {
+	ro.mu.RLock()
+	trie := ro.trie
+	ro.mu.RUnlock()
+
+	t := phoneticKey.Text(sentence)
+	a := getAligner(ro.Scorer, nil)
+	results, err := a.AlignTrie(ctx, t.Runes, trie, ro.Fallback)
+	aligners.Put(a)
+	if err != nil {
+		return nil, err
+	}
+	matches := make([]Match, 0, len(results))
+	for _, r := range results {
+		if r.StartPos > r.EndPos {
+			continue
+		}
+		m := newMatch(t, r)
+		if ro.WholeWord && !wholeWord(sentence, m.Start, m.End) {
+			continue
+		}
+		m.Variant = "romanized"
+		allow(&m, t, r.Entry, ro.Allow)
+		matches = append(matches, m)
+	}
+	return matches, nil
+}
+
+// wholeWord reports whether the letters of s[start:end] begin and end words
+// of s. Alignments often take in a space or mark next to the word, so those
+// are trimmed first.
+func wholeWord(s string, start, end int) bool {
+	start, end = trimWord(s, start, end)
+	return startsWord(s, start) && endsWord(s, end)
+}
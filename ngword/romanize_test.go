@@ -0,0 +1,53 @@
+package ngword
+
+import "testing"
+
+func TestPhoneticKey(t *testing.T) {
+	for _, c := range []struct {
+		word      string
+		spelled   []string
+		unrelated []string
+	}{
+		{"씨발", []string{"ssibal", "shibal", "sibbal", "SSIBAL"}, []string{"sabal", "jonna"}},
+		{"존나", []string{"jonna", "JONNA", "zonna", "jona"}, []string{"ssibal", "jonathan"}},
+	} {
+		key := phoneticKey.String(Romanize(c.word))
+		for _, s := range c.spelled {
+			if got := phoneticKey.String(s); got != key {
+				t.Errorf("%q: got key %q, want %q of %s", s, got, key, c.word)
+			}
+		}
+		for _, s := range c.unrelated {
+			if got := phoneticKey.String(s); got == key {
+				t.Errorf("%q shares the key %q of %s", s, got, c.word)
+			}
+		}
+	}
+}
+
+func TestRomanizedMatches(t *testing.T) {
+	ro, err := NewRomanized(ReadDataframeFromCSV("../resource/ngwords.example.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		sentence string
+		want     string
+	}{
+		{"ssibal", "씨발"},
+		{"shibal what", "씨발"},
+		{"this is jonna good", "존나"},
+		{"Jonathan", ""},
+		{"hello Jonathan, bye", ""},
+	} {
+		var got string
+		for _, m := range ro.Detect(c.sentence) {
+			if !m.Suppressed {
+				got = m.Word
+			}
+		}
+		if got != c.want {
+			t.Errorf("%q: got %q, want %q", c.sentence, got, c.want)
+		}
+	}
+}
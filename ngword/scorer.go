@@ -0,0 +1,97 @@
+package ngword
+
+import (
+	"encoding/json"
+	"golang.org/x/text/unicode/norm"
+	"os"
+)
+
+// Scorer scores aligning the sentence rune a with the word rune b. A zero
+// rune on either side stands for a gap.
+type Scorer interface {
+	Score(a, b rune) int
+}
+
+// ScoreProfile is a Scorer that can be loaded from a JSON file such as
+// resource/scores.default.json. Runes listed in the same group score Similar
+// against each other. Affine, when present, asks filters to use affine gap
+// costs instead of Gap.
+type ScoreProfile struct {
+	Match    int        `json:"match"`
+	Similar  int        `json:"similar"`
+	Space    int        `json:"space"`
+	Mismatch int        `json:"mismatch"`
+	Gap      int        `json:"gap"`
+	Groups   []string   `json:"groups"`
+	Affine   *AffineGap `json:"affine,omitempty"`
+
+	table map[rune]int
+}
+
+// DefaultScorer reproduces the original scoring: the SCORE_ constants and
+// MatchTable, with gaps costing as much as a mismatch.
+var DefaultScorer = &ScoreProfile{
+	Match:    SCORE_MATCH,
+	Similar:  SCORE_SIMILAR,
+	Space:    SCORE_SPACE,
+	Mismatch: SCORE_MISMATCH,
+	Gap:      SCORE_MISMATCH,
+	table:    MatchTable,
+}
+
+// LoadScoreProfile reads a profile from fname. Fields missing from the file
+// keep the values of DefaultScorer, including its similar groups.
+func LoadScoreProfile(fname string) (*ScoreProfile, error) {
+	fp, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	p := *DefaultScorer
+	p.Groups = nil
+	if err := json.NewDecoder(fp).Decode(&p); err != nil {
+		return nil, err
+	}
+	if p.Groups != nil {
+		p.table = make(map[rune]int)
+		for i, g := range p.Groups {
+			for _, r := range norm.NFKD.String(g) {
+				p.table[r] = i
+			}
+		}
+	}
+	return &p, nil
+}
+
+func (p *ScoreProfile) Score(a, b rune) int {
+	if a == b {
+		return p.Match
+	} else if a == ' ' || b == ' ' {
+		return p.Space
+	} else if a == 0 || b == 0 {
+		return p.Gap
+	}
+
+	g1, ok1 := p.table[a]
+	g2, ok2 := p.table[b]
+	if ok1 && ok2 && g1 == g2 {
+		return p.Similar
+	}
+
+	return p.Mismatch
+}
+
+// bounded reports whether no alignment step under p and gap scores above
+// Match and no run of filler in the sentence scores above zero. Only then may
+// Aligner.AlignTrie prune cells that cannot reach a threshold.
+func (p *ScoreProfile) bounded(gap *AffineGap) bool {
+	if p.Match < 0 || p.Similar > p.Match || p.Mismatch > p.Match || p.Space > p.Match {
+		return false
+	}
+	if gap != nil {
+		return gap.Insert.Open <= 0 && gap.Insert.Extend <= 0 &&
+			gap.Delete.Open <= p.Match && gap.Delete.Extend <= p.Match
+	}
+	return p.Space <= 0 && p.Gap <= 0
+}
@@ -1,6 +1,6 @@
 package ngword
 
-import "golang.org/x/text/unicode/norm"
+import "context"
 
 const (
 	SCORE_MATCH    = 5
@@ -18,7 +18,13 @@ type SmithWatermanResult struct {
 	MatchWord                           string
 	CompleteAgreement, AppliedAgreement int
 	SimilarScore                        float32
+	Threshold                           float32
 	StartPos, EndPos                    int
+	// WordEnd is the last position aligned to a rune of the word. EndPos
+	// may run past it over the runes the alignment skips after the word.
+	WordEnd int
+	// Entry is set by SmithWatermanTrie when the dictionary gave one.
+	Entry *Entry
 }
 
 type SmithWatermanEnd struct {
@@ -43,6 +49,23 @@ func (b BySimilar) Less(i, j int) bool {
 	return f1 > f2
 }
 
+// Threshold returns the similarity a word of lenWord normalized runes must
+// reach to be reported, for words without a threshold of their own.
+type Threshold func(lenWord int) float32
+
+// LengthThreshold is the default policy: short words must match almost
+// exactly while longer words tolerate a few more edits.
+func LengthThreshold(lenWord int) float32 {
+	return -0.001*float32(lenWord+1)*float32(lenWord+1) + 0.99
+}
+
+// FixedThreshold uses the same threshold for every word.
+func FixedThreshold(thresh float32) Threshold {
+	return func(int) float32 {
+		return thresh
+	}
+}
+
 type Node struct {
 	Score int
 	Next  int
@@ -76,20 +99,20 @@ var MatchTable = map[rune]int{
 	0x1175: 104, // ㅣ 중성
 }
 
-func Match(a, b rune) int {
-	if a == b {
-		return SCORE_MATCH
-	} else if a == ' ' || b == ' ' {
-		return SCORE_SPACE
-	}
+// MatchScore scores a and b with DefaultScorer. It was named Match until
+// Match became the report of a hit; callers of Match(a, b) should call
+// MatchScore(a, b) or a Scorer.
+func MatchScore(a, b rune) int {
+	return DefaultScorer.Score(a, b)
+}
 
-	g1, ok1 := MatchTable[a]
-	g2, ok2 := MatchTable[b]
-	if ok1 && ok2 && g1 == g2 {
-		return SCORE_SIMILAR
+// perfectScore is the score of word aligned with itself.
+func perfectScore(sc Scorer, word []rune) int {
+	score := 0
+	for _, r := range word {
+		score += sc.Score(r, r)
 	}
-
-	return SCORE_MISMATCH
+	return score
 }
 
 func History(stMatrix [][]Node, lenWord, endPos int) int {
@@ -108,195 +131,59 @@ func History(stMatrix [][]Node, lenWord, endPos int) int {
 	return s
 }
 
-//func SmithWatermanScore(sentence, word []rune) SmithWatermanResult {
-//	lenStc := len(sentence)
-//	lenWord := len(word)
-//
-//	stMatrix := _p.Get().([][]int)
-//	for t := 1; t <= lenWord; t++ {
-//		stMatrix[t][0] = -t
-//	}
-//
-//	for t := 1; t <= lenWord; t++ {
-//		for s := 1; s <= lenStc; s++ {
-//			ijscore := stMatrix[t-1][s-1] + Match(sentence[s-1], word[t-1])
-//			iscore := stMatrix[t-1][s] + Match(rune(0), word[t-1])
-//			jscore := stMatrix[t][s-1] + Match(sentence[s-1], rune(0))
-//
-//			if ijscore >= iscore && ijscore >= jscore {
-//				stMatrix[t][s] = ijscore
-//			} else if iscore >= ijscore && iscore >= jscore {
-//				stMatrix[t][s] = iscore
-//			} else {
-//				stMatrix[t][s] = jscore
-//			}
-//			if stMatrix[t][s] < 0 {
-//				stMatrix[t][s] = 0
-//			}
-//		}
-//	}
-//
-//	completeAgreement := lenWord * SCORE_MATCH
-//	maxAgreement := 0
-//	startPos := 0
-//	for i, s := range stMatrix[lenWord] {
-//		if s > maxAgreement {
-//			maxAgreement = s
-//			startPos = i
-//		}
-//	}
-//
-//	for t := 0; t <= lenWord; t++ {
-//		for s := 0; s <= lenStc; s++ {
-//			stMatrix[t][s] = 0
-//		}
-//	}
-//	_p.Put(stMatrix)
-//	return SmithWatermanResult{
-//		CompleteAgreement: completeAgreement,
-//		AppliedAgreement:  maxAgreement,
-//		SimilarScore:      float32(maxAgreement) / float32(completeAgreement),
-//		StartPos:          startPos,
-//	}
-//}
+// swCell fills cell s of row t from its diagonal, upper and left neighbours.
+func swCell(sc Scorer, diag, up, left Node, cur *Node, x, w rune) {
+	ijscore := diag.Score + sc.Score(x, w)
+	iscore := up.Score + sc.Score(rune(0), w)
+	jscore := left.Score + sc.Score(x, rune(0))
+
+	if ijscore >= iscore && ijscore >= jscore {
+		cur.Score = ijscore
+		cur.Next = NEXT_IJ
+	} else if iscore >= jscore {
+		cur.Score = iscore
+		cur.Next = NEXT_I
+	} else {
+		cur.Score = jscore
+		cur.Next = NEXT_J
+	}
+}
 
-func SmithWaterman(sentence, word []rune, thresh float32) (<-chan SmithWatermanResult, <-chan SmithWatermanEnd) {
+// SmithWaterman streams the hits of word in sentence, then the final row of
+// the matrix. It wraps Aligner.Align.
+func SmithWaterman(sc Scorer, sentence, word []rune, thresh float32) (<-chan SmithWatermanResult, <-chan SmithWatermanEnd) {
+	return streamAlign(NewAligner(sc, nil), sentence, word, thresh)
+}
+
+func streamAlign(a *Aligner, sentence, word []rune, thresh float32) (<-chan SmithWatermanResult, <-chan SmithWatermanEnd) {
 	smithCh := make(chan SmithWatermanResult, 10)
 	endCh := make(chan SmithWatermanEnd)
 
 	go func() {
-		lenStc := len(sentence)
-		lenWord := len(word)
-
-		//stMatrix := _p.Get().([][]Node)
-		stMatrix := make([][]Node, lenWord+1)
-		for i := range stMatrix {
-			stMatrix[i] = make([]Node, lenStc+1)
-		}
-
-		for t := 1; t <= lenWord; t++ {
-			for s := 1; s <= lenStc; s++ {
-				ijscore := stMatrix[t-1][s-1].Score + Match(sentence[s-1], word[t-1])
-				iscore := stMatrix[t-1][s].Score + Match(rune(0), word[t-1])
-				jscore := stMatrix[t][s-1].Score + Match(sentence[s-1], rune(0))
-
-				if ijscore >= iscore && ijscore >= jscore {
-					stMatrix[t][s].Score = ijscore
-					stMatrix[t][s].Next = NEXT_IJ
-				} else if iscore >= jscore {
-					stMatrix[t][s].Score = iscore
-					stMatrix[t][s].Next = NEXT_I
-				} else {
-					stMatrix[t][s].Score = jscore
-					stMatrix[t][s].Next = NEXT_J
-				}
-				/*
-					if stMatrix[t][s].Score < 0 {
-						stMatrix[t][s].Score = 0
-					}
-				*/
-			}
-		}
-
-		completeAgreement := lenWord * SCORE_MATCH
-		threshAgreement := float32(completeAgreement) * thresh
-		maxAgreement := -987654321
-		for i := len(stMatrix[lenWord]) - 1; i >= 0; i-- {
-			v := stMatrix[lenWord][i]
-			if v.Score > maxAgreement {
-				maxAgreement = v.Score
-			}
-		}
-		for i := len(stMatrix[lenWord]) - 1; i >= 0; i-- {
-			v := stMatrix[lenWord][i]
-			if float32(v.Score) > threshAgreement {
-				s := History(stMatrix, lenWord, i)
-				smithCh <- SmithWatermanResult{
-					MatchWord:         string(word),
-					CompleteAgreement: completeAgreement,
-					AppliedAgreement:  v.Score,
-					SimilarScore:      float32(v.Score) / float32(completeAgreement),
-					StartPos:          s,
-					EndPos:            i - 1,
-					//EndPos:            i,
-				}
-				i = s
-			}
-		}
-
-		//for t := 0; t <= lenWord; t++ {
-		//	for s := 0; s <= lenStc; s++ {
-		//		stMatrix[t][s].Score = 0
-		//		stMatrix[t][s].Next = NEXT_END
-		//	}
-		//}
-		//_p.Put(stMatrix)
-		endCh <- SmithWatermanEnd{
-			MatchWord: norm.NFC.String(string(word)),
-			//MatchWord:         string(word),
-			LastNodes:         stMatrix[lenWord],
-			CompleteAgreement: completeAgreement,
-			MaxAgreement:      maxAgreement,
-			ThreshAgreement:   int(float32(completeAgreement) * thresh),
+		results, end, _ := a.Align(context.Background(), sentence, word, thresh)
+		for _, r := range results {
+			smithCh <- r
 		}
+		endCh <- end
 		close(smithCh)
 		close(endCh)
 	}()
 	return smithCh, endCh
 }
 
-func SmithWatermanTrie(sentence []rune, words Trie, thresh float32) <-chan SmithWatermanResult {
+// SmithWatermanTrie streams the hits of every word of words in sentence. It
+// wraps Aligner.AlignTrie.
+func SmithWatermanTrie(sc Scorer, sentence []rune, words Trie, fallback Threshold) <-chan SmithWatermanResult {
+	return streamAlignTrie(NewAligner(sc, nil), sentence, words, fallback)
+}
+
+func streamAlignTrie(a *Aligner, sentence []rune, words Trie, fallback Threshold) <-chan SmithWatermanResult {
 	smithCh := make(chan SmithWatermanResult, 10)
 
 	go func() {
-		lenStc := len(sentence)
-		stMatrix := make([][]Node, 100)
-		for i := range stMatrix {
-			stMatrix[i] = make([]Node, lenStc+1)
-		}
-		word := make([]rune, 100)
-		ch := words.PreOrder()
-		for node := range ch {
-			word[node.Level-1] = node.Value
-			for s := 1; s <= lenStc; s++ {
-				ijscore := stMatrix[node.Level-1][s-1].Score + Match(sentence[s-1], node.Value)
-				iscore := stMatrix[node.Level-1][s].Score + Match(rune(0), node.Value)
-				jscore := stMatrix[node.Level][s-1].Score + Match(sentence[s-1], rune(0))
-
-				if ijscore >= iscore && ijscore >= jscore {
-					stMatrix[node.Level][s].Score = ijscore
-					stMatrix[node.Level][s].Next = NEXT_IJ
-				} else if iscore >= jscore {
-					stMatrix[node.Level][s].Score = iscore
-					stMatrix[node.Level][s].Next = NEXT_I
-				} else {
-					stMatrix[node.Level][s].Score = jscore
-					stMatrix[node.Level][s].Next = NEXT_J
-				}
-			}
-
-			if node.End {
-				matchWord := norm.NFKC.String(string(word[:node.Level]))
-				lenWord := node.Level
-				completeAgreement := lenWord * SCORE_MATCH
-				//threshAgreement := float32(completeAgreement) * thresh
-				threshAgreement := float32(completeAgreement) * (-0.001*float32(lenWord+1)*float32(lenWord+1) + 0.99)
-				for i := len(stMatrix[lenWord]) - 1; i >= 0; i-- {
-					v := stMatrix[lenWord][i]
-					if float32(v.Score) >= threshAgreement {
-						s := History(stMatrix, lenWord, i)
-						smithCh <- SmithWatermanResult{
-							MatchWord:         matchWord,
-							CompleteAgreement: completeAgreement,
-							AppliedAgreement:  v.Score,
-							SimilarScore:      float32(v.Score) / float32(completeAgreement),
-							StartPos:          s,
-							EndPos:            i - 1,
-						}
-						i = s
-					}
-				}
-			}
+		results, _ := a.AlignTrie(context.Background(), sentence, words, fallback)
+		for _, r := range results {
+			smithCh <- r
 		}
 		close(smithCh)
 	}()
@@ -0,0 +1,230 @@
+package ngword
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// Replacer masks the NG words of one sentence.
+type Replacer interface {
+	Replace(sentence string) (string, bool)
+}
+
+// contextReplacer is a Replacer that can give up on a sentence midway.
+type contextReplacer interface {
+	ReplaceContext(ctx context.Context, sentence string) (string, bool, error)
+}
+
+// Result is one filtered sentence of a stream. Index counts the sentences
+// read from the input, from zero. Toxicity is only rated by filters that
+// report their matches, such as LocalAlignmentTrie and Pipeline, and is zero
+// with the others. Action is the decision of those filters, and Mask or Pass
+// with the others as the sentence changed or not.
+type Result struct {
+	Index    int
+	Sentence string
+	Filtered string
+	Changed  bool
+	Action   Action
+	Toxicity float64
+	Err      error
+}
+
+// Stream filters sentences on a pool of workers without holding more than a
+// few of them in memory.
+type Stream struct {
+	Replacer Replacer
+	// Workers is the number of sentences filtered at once, runtime.NumCPU()
+	// when zero.
+	Workers int
+	// Progress, when set, is called with the number of sentences delivered
+	// so far after each one.
+	Progress func(done int)
+}
+
+func NewStream(r Replacer) *Stream {
+	return &Stream{Replacer: r}
+}
+
+// replace fills in the filtered sentence of r.
+func (s *Stream) replace(ctx context.Context, r *Result) {
+	switch f := s.Replacer.(type) {
+	case decider:
+		var d Decision
+		d, r.Err = f.DecideContext(ctx, r.Sentence)
+		r.Filtered, r.Action, r.Toxicity = d.Filtered, d.Action, d.Toxicity
+		r.Changed = d.Action != Pass
+		return
+	case contextReplacer:
+		r.Filtered, r.Changed, r.Err = f.ReplaceContext(ctx, r.Sentence)
+	default:
+		if r.Err = ctx.Err(); r.Err != nil {
+			r.Filtered = r.Sentence
+			return
+		}
+		r.Filtered, r.Changed = s.Replacer.Replace(r.Sentence)
+	}
+	if r.Changed {
+		r.Action = Mask
+	}
+}
+
+// FilterStream filters the sentences of in until it is closed and delivers
+// them in input order. Reading in stalls while the caller falls behind. Once
+// ctx is done the output is closed early and the rest of in is left unread.
+func (s *Stream) FilterStream(ctx context.Context, in <-chan string) <-chan Result {
+	workers := s.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	jobs := make(chan Result, workers)
+	done := make(chan Result, workers)
+	out := make(chan Result, workers)
+	// window bounds the sentences read but not delivered yet
+	window := make(chan struct{}, 2*workers)
+
+	go func() {
+		defer close(jobs)
+		for i := 0; ; i++ {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			var r Result
+			var ok bool
+			select {
+			case r.Sentence, ok = <-in:
+				if !ok {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+			r.Index = i
+			select {
+			case jobs <- r:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	wg := &sync.WaitGroup{}
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for r := range jobs {
+				s.replace(ctx, &r)
+				select {
+				case done <- r:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	go func() {
+		defer close(out)
+		pending := make(map[int]Result)
+		next := 0
+		for r := range done {
+			pending[r.Index] = r
+			for {
+				r, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				select {
+				case out <- r:
+				case <-ctx.Done():
+					return
+				}
+				next++
+				<-window
+				if s.Progress != nil {
+					s.Progress(next)
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// FilterStrings filters stcs and returns their results in order.
+func (s *Stream) FilterStrings(stcs []string) []Result {
+	ctx := context.Background()
+	results := make([]Result, 0, len(stcs))
+	for r := range s.FilterStream(ctx, Strings(ctx, stcs)) {
+		results = append(results, r)
+	}
+	return results
+}
+
+// FilterReader filters in line by line and writes the filtered lines to out
+// in the same order. It stops at the first error.
+func (s *Stream) FilterReader(in io.Reader, out io.Writer) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var scanErr error
+	w := bufio.NewWriter(out)
+	for r := range s.FilterStream(ctx, Lines(ctx, in, &scanErr)) {
+		if r.Err != nil {
+			return r.Err
+		}
+		if _, err := fmt.Fprintln(w, r.Filtered); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return scanErr
+}
+
+// Lines feeds the lines of r to a stream, stopping early once ctx is done.
+// The read error, if any, is stored in *err before the channel is closed.
+func Lines(ctx context.Context, r io.Reader, err *error) <-chan string {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 64*1024), 1024*1024)
+		for sc.Scan() {
+			select {
+			case lines <- sc.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+		*err = sc.Err()
+	}()
+	return lines
+}
+
+// Strings feeds stcs to a stream, stopping early once ctx is done.
+func Strings(ctx context.Context, stcs []string) <-chan string {
+	in := make(chan string)
+	go func() {
+		defer close(in)
+		for _, s := range stcs {
+			select {
+			case in <- s:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return in
+}
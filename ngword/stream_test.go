@@ -0,0 +1,127 @@
+package ngword
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowUpper upper-cases sentences, taking longer on some so that workers
+// finish out of order.
+type slowUpper struct{}
+
+func (slowUpper) Replace(sentence string) (string, bool) {
+	time.Sleep(time.Duration(len(sentence)%4) * time.Millisecond)
+	return strings.ToUpper(sentence), true
+}
+
+// waiter gives up on every sentence only once ctx is done.
+type waiter struct{}
+
+func (waiter) Replace(sentence string) (string, bool) {
+	return sentence, false
+}
+
+func (waiter) ReplaceContext(ctx context.Context, sentence string) (string, bool, error) {
+	<-ctx.Done()
+	return sentence, false, ctx.Err()
+}
+
+func TestStreamKeepsOrder(t *testing.T) {
+	stcs := make([]string, 200)
+	for i := range stcs {
+		stcs[i] = fmt.Sprintf("s%d%s", i, strings.Repeat("x", i%7))
+	}
+	st := NewStream(slowUpper{})
+	st.Workers = 8
+	var progress []int
+	st.Progress = func(done int) {
+		progress = append(progress, done)
+	}
+	results := st.FilterStrings(stcs)
+	if len(results) != len(stcs) {
+		t.Fatalf("got %d results, want %d", len(results), len(stcs))
+	}
+	for i, r := range results {
+		if r.Index != i || r.Sentence != stcs[i] || r.Filtered != strings.ToUpper(stcs[i]) || r.Action != Mask {
+			t.Fatalf("result %d is %+v", i, r)
+		}
+	}
+	for i, done := range progress {
+		if done != i+1 {
+			t.Fatalf("progress reported %v", progress)
+		}
+	}
+}
+
+// drain reads out until it is closed, failing when that takes too long.
+func drain(t *testing.T, out <-chan Result) []Result {
+	t.Helper()
+	var results []Result
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case r, ok := <-out:
+			if !ok {
+				return results
+			}
+			results = append(results, r)
+		case <-deadline:
+			t.Fatal("the stream kept going after its context was cancelled")
+		}
+	}
+}
+
+func TestStreamStopsOnCancel(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+	// an endless input, counting the sentences the stream took
+	var read int64
+	in := make(chan string)
+	go func() {
+		for {
+			select {
+			case in <- "x":
+				atomic.AddInt64(&read, 1)
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	st := NewStream(slowUpper{})
+	st.Workers = 2
+	out := st.FilterStream(ctx, in)
+	for i := 0; i < 5; i++ {
+		<-out
+	}
+	cancel()
+	delivered := 5 + len(drain(t, out))
+
+	before := atomic.LoadInt64(&read)
+	time.Sleep(20 * time.Millisecond)
+	if after := atomic.LoadInt64(&read); after != before {
+		t.Errorf("the stream read %d more sentences after it closed", after-before)
+	}
+	// the window holds back the reading while the caller falls behind
+	if before > int64(delivered+2*st.Workers) {
+		t.Errorf("the stream read %d sentences for %d delivered", before, delivered)
+	}
+}
+
+func TestStreamStopsMidSentence(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	st := NewStream(waiter{})
+	st.Workers = 2
+	out := st.FilterStream(ctx, Strings(ctx, []string{"a", "b", "c"}))
+	time.AfterFunc(10*time.Millisecond, cancel)
+	for _, r := range drain(t, out) {
+		if r.Err == nil {
+			t.Errorf("result %d has no error after the cancel", r.Index)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package ngword
+
+import (
+	"golang.org/x/text/unicode/norm"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Span is a half-open byte range in the original string.
+type Span struct {
+	Start, End int
+}
+
+// Text is the NFKD form of a string that remembers which bytes of the
+// original string every normalized rune came from. Alignment runs over Runes,
+// and results are mapped back through Spans so callers only ever see offsets
+// into the string they passed in.
+type Text struct {
+	Origin string
+	Runes  []rune
+	Spans  []Span
+
+	// starts holds the byte offset of every rune of Origin once RuneOffset
+	// needs it
+	starts []int
+}
+
+// NewText decomposes s segment by segment. Every rune of a segment, i.e. a
+// character together with anything that composes with it under NFC, shares
+// the segment's span, so a mask can never split a character.
+func NewText(s string) *Text {
+	t := &Text{
+		Origin: s,
+		Runes:  make([]rune, 0, len(s)),
+		Spans:  make([]Span, 0, len(s)),
+	}
+	for i := 0; i < len(s); {
+		n := norm.NFC.NextBoundaryInString(s[i:], true)
+		if n <= 0 {
+			_, n = utf8.DecodeRuneInString(s[i:])
+		}
+		sp := Span{i, i + n}
+		for _, r := range norm.NFKD.String(s[sp.Start:sp.End]) {
+			t.Runes = append(t.Runes, r)
+			t.Spans = append(t.Spans, sp)
+		}
+		i += n
+	}
+	return t
+}
+
+// Span returns the original bytes covered by the normalized runes
+// start..end inclusive, the convention used by SmithWatermanResult.
+func (t *Text) Span(start, end int) Span {
+	return Span{t.Spans[start].Start, t.Spans[end].End}
+}
+
+// RuneOffset converts a byte offset in Origin into a rune offset.
+func (t *Text) RuneOffset(pos int) int {
+	if t.starts == nil {
+		t.starts = make([]int, 0, len(t.Origin))
+		for i := range t.Origin {
+			t.starts = append(t.starts, i)
+		}
+	}
+	return sort.SearchInts(t.starts, pos)
+}
+
+// Mask replaces every original character overlapped by spans with rep. A
+// masked character becomes one rep per rune of its NFKD form so the output
+// keeps the width of the old jamo-level masking, whatever a Normalization
+// dropped or added. The characters a Normalization dropped, such as zero-width
+// spaces, are masked too when spans cover them.
+func (t *Text) Mask(spans []Span, rep rune) string {
+	if len(spans) == 0 {
+		return t.Origin
+	}
+	masked := make([]bool, len(t.Origin))
+	for _, sp := range spans {
+		for i := sp.Start; i < sp.End; i++ {
+			masked[i] = true
+		}
+	}
+
+	var b strings.Builder
+	b.Grow(len(t.Origin))
+	last := 0
+	// walk the characters of Origin the way NewText cut them, rather than
+	// the spans of Runes, which skip the dropped ones
+	for i := 0; i < len(t.Origin); {
+		n := norm.NFC.NextBoundaryInString(t.Origin[i:], true)
+		if n <= 0 {
+			_, n = utf8.DecodeRuneInString(t.Origin[i:])
+		}
+		sp := Span{i, i + n}
+		if t.masked(masked, sp) {
+			b.WriteString(t.Origin[last:sp.Start])
+			k := utf8.RuneCountInString(norm.NFKD.String(t.Origin[sp.Start:sp.End]))
+			b.WriteString(strings.Repeat(string(rep), k))
+			last = sp.End
+		}
+		i += n
+	}
+	b.WriteString(t.Origin[last:])
+	return b.String()
+}
+
+func (t *Text) masked(masked []bool, sp Span) bool {
+	for i := sp.Start; i < sp.End; i++ {
+		if masked[i] {
+			return true
+		}
+	}
+	return false
+}
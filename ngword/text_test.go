@@ -0,0 +1,72 @@
+package ngword
+
+import (
+	"testing"
+)
+
+func TestTextSpans(t *testing.T) {
+	// 씨 decomposes to two jamo, 발 to three; "a" and the space to one each
+	text := NewText("a 씨발")
+	if got, want := len(text.Runes), 7; got != want {
+		t.Fatalf("got %d runes, want %d", got, want)
+	}
+	for _, c := range []struct {
+		start, end int
+		want       Span
+	}{
+		{0, 0, Span{0, 1}},
+		{2, 2, Span{2, 5}},
+		{3, 4, Span{2, 8}},
+		{4, 6, Span{5, 8}},
+	} {
+		if got := text.Span(c.start, c.end); got != c.want {
+			t.Errorf("Span(%d, %d) = %v, want %v", c.start, c.end, got, c.want)
+		}
+	}
+	if got := text.RuneOffset(5); got != 3 {
+		t.Errorf("RuneOffset(5) = %d, want 3", got)
+	}
+	// a span ending inside 발 masks the whole syllable
+	if got, want := text.Mask([]Span{{6, 7}}, '*'), "a 씨***"; got != want {
+		t.Errorf("Mask gives %q, want %q", got, want)
+	}
+}
+
+// TestMatchOffsets checks that matches end with the word, not with the
+// separator the alignment runs over after it.
+func TestMatchOffsets(t *testing.T) {
+	la, err := NewLocalAlignmentTrie(EntriesFrame(loadEntries(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	la.Variants = []Variant{DubeolsikVariant}
+	for _, c := range []struct {
+		sentence, word string
+		want           Match
+		masked         string
+	}{
+		{"야 씨발 뭐야", "씨발", Match{Start: 4, End: 10, RuneStart: 2, RuneEnd: 4}, "야 ***** 뭐야"},
+		{"tlqkf 뭐야", "씨발", Match{Start: 0, End: 5, RuneStart: 0, RuneEnd: 5}, "***** 뭐야"},
+	} {
+		found := false
+		for _, m := range la.Detect(c.sentence) {
+			if m.Word != c.word {
+				continue
+			}
+			found = true
+			if m.Start != c.want.Start || m.End != c.want.End || m.RuneStart != c.want.RuneStart || m.RuneEnd != c.want.RuneEnd {
+				t.Errorf("%q: %s at [%d,%d) runes [%d,%d), want [%d,%d) runes [%d,%d)", c.sentence, m.Word,
+					m.Start, m.End, m.RuneStart, m.RuneEnd, c.want.Start, c.want.End, c.want.RuneStart, c.want.RuneEnd)
+			}
+		}
+		if !found {
+			t.Errorf("%q: %s not found", c.sentence, c.word)
+		}
+		if got, _ := la.Replace(c.sentence); got != c.masked {
+			t.Errorf("%q: masked %q, want %q", c.sentence, got, c.masked)
+		}
+		if got := (Masking{}).Apply(c.sentence, la.Detect(c.sentence)); got != c.masked {
+			t.Errorf("%q: full mask gives %q, want %q", c.sentence, got, c.masked)
+		}
+	}
+}
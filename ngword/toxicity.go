@@ -0,0 +1,79 @@
+package ngword
+
+import (
+	"context"
+	"sort"
+)
+
+// Toxicity rates a sentence from its matches, between 0 for a clean sentence
+// and 1. A match weighs its similarity times its severity over MaxSeverity,
+// so that a verbatim word of the worst severity weighs 1. Overlapping matches
+// count once, with the weight of the heaviest, and the weights w of the
+// stretches they leave are combined as 1 - (1-w1)(1-w2)...: every further
+// NG word raises the score without ever reaching past 1. Suppressed matches
+// are ignored.
+func Toxicity(matches []Match) float64 {
+	type weighted struct {
+		Span
+		w float64
+	}
+	ws := make([]weighted, 0, len(matches))
+	for _, m := range matches {
+		if m.Suppressed {
+			continue
+		}
+		score := float64(m.Score)
+		if score > 1 {
+			score = 1
+		} else if score < 0 {
+			score = 0
+		}
+		ws = append(ws, weighted{Span{m.Start, m.End}, score * float64(m.Severity) / MaxSeverity})
+	}
+	sort.Slice(ws, func(i, j int) bool {
+		return ws[i].Start < ws[j].Start
+	})
+
+	clean := 1.0
+	for i := 0; i < len(ws); {
+		end, w := ws[i].End, ws[i].w
+		j := i + 1
+		for ; j < len(ws) && ws[j].Start < end; j++ {
+			if ws[j].End > end {
+				end = ws[j].End
+			}
+			if ws[j].w > w {
+				w = ws[j].w
+			}
+		}
+		clean *= 1 - w
+		i = j
+	}
+	return 1 - clean
+}
+
+// decider is a Replacer that can tell the whole Decision on a sentence, and
+// with it its Toxicity.
+type decider interface {
+	DecideContext(ctx context.Context, sentence string) (Decision, error)
+}
+
+// replaced gives the ReplaceContext of a decider from its DecideContext.
+func replaced(d Decision, err error) (string, bool, error) {
+	return d.Filtered, d.Action != Pass, err
+}
+
+// maskDecision is the Decision of a filter that masks every match, filtered
+// being sentence masked.
+func maskDecision(sentence, filtered string, matches []Match) Decision {
+	d := Decision{
+		Filtered: filtered,
+		Matches:  matches,
+		Spans:    MergeSpans(Spans(matches)),
+		Toxicity: Toxicity(matches),
+	}
+	if len(d.Spans) > 0 {
+		d.Action = Mask
+	}
+	return d
+}
@@ -0,0 +1,32 @@
+package ngword
+
+import (
+	"math"
+	"testing"
+)
+
+func TestToxicity(t *testing.T) {
+	m := func(start, end, severity int, score float32) Match {
+		return Match{Start: start, End: end, Severity: severity, Score: score}
+	}
+	suppressed := m(0, 6, 5, 1)
+	suppressed.Suppressed = true
+	for _, c := range []struct {
+		name    string
+		matches []Match
+		want    float64
+	}{
+		{"clean", nil, 0},
+		{"verbatim worst word", []Match{m(0, 6, 5, 1)}, 1},
+		{"similarity times severity", []Match{m(0, 6, 4, 0.5)}, 0.4},
+		{"two words combine", []Match{m(0, 6, 5, 0.5), m(10, 16, 5, 0.5)}, 0.75},
+		{"overlapping words count once", []Match{m(0, 6, 5, 0.5), m(3, 9, 5, 0.5)}, 0.5},
+		{"with the heaviest weight", []Match{m(0, 6, 1, 1), m(3, 9, 5, 0.8), m(4, 5, 5, 0.2)}, 0.8},
+		{"scores beyond 1 are capped", []Match{m(0, 6, 5, 1.3)}, 1},
+		{"suppressed words are ignored", []Match{suppressed, m(10, 16, 5, 0.5)}, 0.5},
+	} {
+		if got := Toxicity(c.matches); math.Abs(got-c.want) > 1e-6 {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
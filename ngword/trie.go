@@ -2,7 +2,6 @@ package ngword
 
 import (
 	"fmt"
-	"strings"
 	"unicode"
 )
 
@@ -15,6 +14,8 @@ type TrieNode struct {
 	Value    rune
 	Children map[rune]*TrieNode
 	End      bool
+	// Entry is the dictionary entry of the word ending here, if any.
+	Entry *Entry
 }
 
 func NewTrie() Trie {
@@ -32,6 +33,12 @@ func NewTrieNode(l int, v rune) *TrieNode {
 }
 
 func (this *Trie) Append(txt string) {
+	this.AppendEntry(txt, nil)
+}
+
+// AppendEntry inserts the key txt, the normalized form of e.Word, and
+// records e on the terminal node.
+func (this *Trie) AppendEntry(txt string, e *Entry) {
 	if len(txt) < 1 {
 		return
 	}
@@ -45,6 +52,7 @@ func (this *Trie) Append(txt string) {
 	}
 
 	node.End = true
+	node.Entry = e
 }
 
 func isNoneChar(r rune) bool {
@@ -58,59 +66,80 @@ func replaceChars(words []rune, start, end int, rep rune) {
 	}
 }
 
+// Replace masks with rep every dictionary word found in txt, ignoring case
+// and the separators inside a word. From each position the longest word is
+// masked, up to its last letter.
 func (this *Trie) Replace(txt string, rep rune) (string, bool) {
 	if txt == "" {
 		return "", false
 	}
-	origin := []rune(strings.ToLower(txt))
 	words := []rune(txt)
+	origin := make([]rune, len(words))
+	for i, r := range words {
+		origin[i] = unicode.ToLower(r)
+	}
 	replace := false
-	var (
-		ok   bool
-		node *TrieNode
-	)
 	for i, word := range origin {
-		if node, ok = this.Root.Children[word]; !ok {
+		node, ok := this.Root.Children[word]
+		if !ok {
 			continue
 		}
-		j := i + 1
+		end := -1
 		if node.End {
-			replaceChars(words, i, j, rep)
+			end = i + 1
 		}
-		for ; j < len(origin); j++ {
+		for j := i + 1; j < len(origin); j++ {
 			if !isNoneChar(origin[j]) {
 				continue
 			}
-			if v, ok := node.Children[origin[j]]; !ok {
+			if node, ok = node.Children[origin[j]]; !ok {
 				break
-			} else {
-				node = v
+			}
+			if node.End {
+				end = j + 1
 			}
 		}
-		if node.End {
+		if end > 0 {
 			replace = true
-			replaceChars(words, i, j, rep)
+			replaceChars(words, i, end, rep)
 		}
 	}
 	return string(words), replace
 }
 
+// PreOrder streams the nodes below the root in pre-order. It wraps Walk.
 func (this *Trie) PreOrder() <-chan *TrieNode {
 	nodeCh := make(chan *TrieNode, 10)
 	go func() {
-		for _, child := range this.Root.Children {
-			preOrder(child, nodeCh)
-		}
+		this.Walk(func(node *TrieNode) bool {
+			nodeCh <- node
+			return true
+		})
 		close(nodeCh)
 	}()
 	return nodeCh
 }
 
-func preOrder(node *TrieNode, ch chan<- *TrieNode) {
-	ch <- node
+// Walk calls fn with the nodes below the root in pre-order until fn returns
+// false.
+func (this *Trie) Walk(fn func(*TrieNode) bool) {
+	for _, child := range this.Root.Children {
+		if !walk(child, fn) {
+			return
+		}
+	}
+}
+
+func walk(node *TrieNode, fn func(*TrieNode) bool) bool {
+	if !fn(node) {
+		return false
+	}
 	for _, child := range node.Children {
-		preOrder(child, ch)
+		if !walk(child, fn) {
+			return false
+		}
 	}
+	return true
 }
 
 func (this *Trie) Print() {
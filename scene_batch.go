@@ -1,30 +1,50 @@
 package main
 
 import (
+	"context"
 	"ebitenprac/ngword"
 	"ebitenprac/turi"
+	"errors"
 	"fmt"
-	"github.com/go-gota/gota/dataframe"
-	"github.com/go-gota/gota/series"
 	"github.com/hajimehoshi/ebiten"
 	"github.com/hajimehoshi/ebiten/ebitenutil"
 	"image"
 	"log"
+	"sort"
 	"strings"
 )
 
+// batchLinesPerFrame bounds the output lines added in one Update so large
+// batches do not stall a frame.
+const batchLinesPerFrame = 500
+
 type BatchScene struct {
 	input      *turi.TextBox
 	output     *turi.TextBox
 	execBtn    *turi.Button
 	uniqueBtn  *turi.Button
 	summaryBtn *turi.Button
-	filter     *ngword.LocalAlignmentTrie
+	cancelBtn  *turi.Button
+	worstBtn   *turi.Button
+	progress   *turi.ProgressBar
+	filter     *ngword.Pipeline
+	dict       *ngword.DictionaryManager
+
+	// results is the running batch, nil when idle
+	results <-chan ngword.Result
+	cancel  context.CancelFunc
+	done    int
+	total   int
+	// toxicity rates the sentences filtered so far, by output line
+	toxicity []float64
 }
 
-func NewBatchScene() *BatchScene {
+func NewBatchScene(dict *ngword.DictionaryManager) *BatchScene {
 	//la := ngword.NewLocalAlignment(ngword.ReadDataframeFromCSV("resource/ngwords.new.plain.csv"))
-	la := ngword.NewLocalAlignmentTrie(ngword.ReadDataframeFromCSV("resource/ngwords.origin.csv"))
+	p, err := newPipeline(dict)
+	if err != nil {
+		log.Fatal(err)
+	}
 	tb1 := &turi.TextBox{
 		Rect: image.Rect(16, 16, screenWidth/2-16, screenHeight-128),
 	}
@@ -38,16 +58,6 @@ func NewBatchScene() *BatchScene {
 		Rect: image.Rect(16, screenHeight-112, 96, screenHeight-88),
 		Text: "Execute",
 	}
-	btn.SetOnPressed(func(b *turi.Button) {
-		stcs := strings.Split(tb1.Text(false), "\n")
-		df := dataframe.New(series.Strings(stcs))
-		rep, err := la.Do(df)
-		if err != nil {
-			log.Print(err)
-		}
-		rec := rep.Col("filtered").Records()
-		tb2.SetText(strings.Join(rec, "\n"))
-	})
 	uni := &turi.Button{
 		Rect: image.Rect(128, screenHeight-112, 208, screenHeight-88),
 		Text: "Unique",
@@ -67,7 +77,7 @@ func NewBatchScene() *BatchScene {
 		a2 := make([]string, 0, len(t2))
 		a1 := make([]string, 0, len(t1))
 		for i := range t2 {
-			if strings.Index(t2[i], "***") < 0 {
+			if i >= len(t1) || t2[i] == t1[i] {
 				continue
 			}
 			a1 = append(a1, t1[i])
@@ -77,13 +87,141 @@ func NewBatchScene() *BatchScene {
 		tb2.SetText(strings.Join(a2, "\n"))
 	})
 
-	return &BatchScene{
+	cancel := &turi.Button{
+		Rect: image.Rect(320, screenHeight-112, 400, screenHeight-88),
+		Text: "Cancel",
+	}
+	worst := &turi.Button{
+		Rect: image.Rect(416, screenHeight-112, 496, screenHeight-88),
+		Text: "Worst",
+	}
+	progress := &turi.ProgressBar{
+		Rect: image.Rect(512, screenHeight-112, screenWidth-16, screenHeight-88),
+	}
+
+	s := &BatchScene{
 		input:      tb1,
 		output:     tb2,
 		execBtn:    btn,
 		uniqueBtn:  uni,
 		summaryBtn: summary,
-		filter:     la,
+		cancelBtn:  cancel,
+		worstBtn:   worst,
+		progress:   progress,
+		filter:     p,
+		dict:       dict,
+	}
+	btn.SetOnPressed(func(b *turi.Button) {
+		s.start(strings.Split(tb1.Text(false), "\n"))
+	})
+	cancel.SetOnPressed(func(b *turi.Button) {
+		if s.cancel != nil {
+			s.cancel()
+		}
+	})
+	worst.SetOnPressed(func(b *turi.Button) {
+		s.sortWorst()
+	})
+	return s
+}
+
+// sortWorst orders the input and output lines by the toxicity of the
+// sentences, the worst first, so they can be reviewed in that order.
+func (s *BatchScene) sortWorst() {
+	t1 := strings.Split(s.input.Text(false), "\n")
+	t2 := strings.Split(s.output.Text(false), "\n")
+	if len(t2) > len(t1) {
+		t2 = t2[:len(t1)]
+	}
+	if len(t2) > len(s.toxicity) {
+		t2 = t2[:len(s.toxicity)]
+	}
+	idx := make([]int, len(t2))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		return s.toxicity[idx[i]] > s.toxicity[idx[j]]
+	})
+	a1 := make([]string, 0, len(t1))
+	a2 := make([]string, 0, len(t2))
+	tox := make([]float64, 0, len(s.toxicity))
+	for _, i := range idx {
+		a1 = append(a1, t1[i])
+		a2 = append(a2, t2[i])
+		tox = append(tox, s.toxicity[i])
+	}
+	s.toxicity = append(tox, s.toxicity[len(t2):]...)
+	// lines not filtered yet stay at the end
+	a1 = append(a1, t1[len(t2):]...)
+	s.input.SetText(strings.Join(a1, "\n"))
+	s.output.SetText(strings.Join(a2, "\n"))
+}
+
+// start filters stcs in the background, replacing the output as the lines
+// come back. A batch already running is cancelled first.
+func (s *BatchScene) start(stcs []string) {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.results = ngword.NewStream(s.filter).FilterStream(ctx, ngword.Strings(ctx, stcs))
+	s.done, s.total = 0, len(stcs)
+	s.toxicity = s.toxicity[:0]
+	s.output.SetText("")
+	s.progress.Value = 0
+	s.progress.Text = fmt.Sprintf("0 / %d", s.total)
+}
+
+// collect moves the lines filtered since the last frame into the output.
+func (s *BatchScene) collect() {
+	if s.results == nil {
+		return
+	}
+	lines := make([]string, 0, batchLinesPerFrame)
+	finished := false
+	var err error
+loop:
+	for len(lines) < batchLinesPerFrame {
+		select {
+		case r, ok := <-s.results:
+			if !ok {
+				finished = true
+				break loop
+			}
+			if r.Err != nil {
+				finished, err = true, r.Err
+				break loop
+			}
+			lines = append(lines, r.Filtered)
+			s.toxicity = append(s.toxicity, r.Toxicity)
+		default:
+			break loop
+		}
+	}
+	if len(lines) > 0 {
+		txt := strings.Join(lines, "\n")
+		if s.done > 0 {
+			txt = "\n" + txt
+		}
+		s.output.AppendText(txt)
+		s.done += len(lines)
+	}
+
+	s.progress.Text = fmt.Sprintf("%d / %d", s.done, s.total)
+	if s.total > 0 {
+		s.progress.Value = float64(s.done) / float64(s.total)
+	}
+	if finished {
+		switch {
+		case err != nil && !errors.Is(err, context.Canceled):
+			s.progress.Text += " " + err.Error()
+		case s.done < s.total:
+			s.progress.Text += " cancelled"
+		}
+		s.cancel()
+		s.results, s.cancel = nil, nil
 	}
 }
 
@@ -93,16 +231,26 @@ func (s *BatchScene) Update(g *turi.GameState) error {
 	s.execBtn.Update(g.Input)
 	s.uniqueBtn.Update(g.Input)
 	s.summaryBtn.Update(g.Input)
+	s.cancelBtn.Update(g.Input)
+	s.worstBtn.Update(g.Input)
+	s.collect()
 	return nil
 }
 
 func (s *BatchScene) Draw(screen *ebiten.Image) {
-	ebitenutil.DebugPrint(screen, fmt.Sprintf("%3.1f", ebiten.CurrentTPS()))
+	msg := fmt.Sprintf("%3.1f", ebiten.CurrentTPS())
+	if err := s.dict.Err(); err != nil {
+		msg += " dictionary: " + err.Error()
+	}
+	ebitenutil.DebugPrint(screen, msg)
 	s.input.Draw(screen)
 	s.output.Draw(screen)
 	s.execBtn.Draw(screen)
 	s.uniqueBtn.Draw(screen)
 	s.summaryBtn.Draw(screen)
+	s.cancelBtn.Draw(screen)
+	s.worstBtn.Draw(screen)
+	s.progress.Draw(screen)
 }
 
 func Unique(slice []string) []string {
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"ebitenprac/ngword"
 	"ebitenprac/turi"
+	"fmt"
 	"github.com/golang/freetype/truetype"
 	"github.com/hajimehoshi/ebiten"
 	"github.com/hajimehoshi/ebiten/text"
@@ -28,9 +29,10 @@ type UI struct {
 	sResult   ngword.SmithWatermanEnd
 	barGraph  *ebiten.Image
 	filter    *ngword.LocalAlignmentDebug
+	pipeline  *ngword.Pipeline
 }
 
-func NewUI() *UI {
+func NewUI(dict *ngword.DictionaryManager) *UI {
 	ui := &UI{}
 
 	b, err := ioutil.ReadFile("resource/malgun.ttf")
@@ -66,14 +68,22 @@ func NewUI() *UI {
 		ui.button1.Press()
 	})
 	ui.button1.SetOnPressed(func(b *turi.Button) {
-		ui.debug = ""
-
-		replaced, _ := ui.filter.Replace(ui.TextLine1.Text(false))
+		sentence := ui.TextLine1.Text(false)
+		d := ui.pipeline.Decide(sentence)
+		ui.debug = d.Action.String()
+		for _, m := range d.Matches {
+			ui.debug += fmt.Sprintf(" %s(%.2f)", m.Word, m.Score)
+		}
+		ui.filter.Replace(sentence)
 		//vs := make([]float64, len(ui.filter.End))
 		//ticks := make([]string, len(ui.filter.End))
 		vs := make([]float64, 10)
 		ticks := make([]string, 10)
-		for i, e := range ui.filter.End[:10] {
+		top := ui.filter.End
+		if len(top) > 10 {
+			top = top[:10]
+		}
+		for i, e := range top {
 			vs[i] = float64(e.MaxAgreement) / float64(e.CompleteAgreement)
 			ticks[i] = e.MatchWord
 		}
@@ -84,7 +94,7 @@ func NewUI() *UI {
 			log.Fatal(err)
 		}
 
-		ui.TextLine2.SetText(norm.NFC.String(string(replaced)))
+		ui.TextLine2.SetText(norm.NFC.String(d.Filtered))
 	})
 
 	ui.barGraph, err = NewBarGraph([]float64{0.0}, []string{"One"})
@@ -93,8 +103,15 @@ func NewUI() *UI {
 	}
 
 	//df := ngword.ReadDataframeFromCSV("resource/ngwords.new.plain.csv")
-	df := ngword.ReadDataframeFromCSV("resource/ngwords.origin.csv")
-	ui.filter = ngword.NewLocalAlignmentDebug(df)
+	if ui.filter, err = ngword.NewLocalAlignmentDebug(dict.Dictionary()); err != nil {
+		log.Fatal(err)
+	}
+	if err := dict.Register(ui.filter); err != nil {
+		log.Fatal(err)
+	}
+	if ui.pipeline, err = newPipeline(dict); err != nil {
+		log.Fatal(err)
+	}
 
 	return ui
 }
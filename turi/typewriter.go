@@ -88,3 +88,9 @@ func (w *TypeWriter) SetText(txt string) {
 	w.text = txt
 	w.cursor = len(w.text)
 }
+
+// AppendText adds txt after the text and moves the cursor to the end.
+func (w *TypeWriter) AppendText(txt string) {
+	w.text += txt
+	w.cursor = len(w.text)
+}
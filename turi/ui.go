@@ -175,6 +175,33 @@ func (b *Button) SetOnPressed(f func(b *Button)) {
 	b.onPressed = f
 }
 
+// ProgressBar shows Value, from 0 to 1, as a filled bar with Text over it.
+type ProgressBar struct {
+	Rect  image.Rectangle
+	Value float64
+	Text  string
+}
+
+func (p *ProgressBar) Draw(dst *ebiten.Image) {
+	drawNinePatches(dst, p.Rect, imageSrcRects[imageTypeTextLine])
+
+	v := p.Value
+	if v < 0 {
+		v = 0
+	} else if v > 1 {
+		v = 1
+	}
+	inner := p.Rect.Inset(2)
+	w := float64(inner.Dx()) * v
+	ebitenutil.DrawRect(dst, float64(inner.Min.X), float64(inner.Min.Y), w, float64(inner.Dy()), color.RGBA{0x44, 0x9a, 0xae, 0xff})
+
+	bounds, _ := font.BoundString(uiFont, p.Text)
+	tw := (bounds.Max.X - bounds.Min.X).Ceil()
+	x := p.Rect.Min.X + (p.Rect.Dx()-tw)/2
+	y := p.Rect.Max.Y - (p.Rect.Dy()-uiFontMHeight)/2
+	text.Draw(dst, p.Text, uiFont, x, y, color.Black)
+}
+
 const (
 	TextLinePaddingLeft = 8
 	LineHeight          = 16